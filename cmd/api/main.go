@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"rytr/internal/server"
@@ -14,6 +15,7 @@ func main() {
 	server := server.New()
 
 	server.RegisterFiberRoutes()
+	server.StartBackgroundJobs(context.Background())
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	err := server.Listen(fmt.Sprintf(":%d", port))
 	if err != nil {
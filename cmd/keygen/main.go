@@ -0,0 +1,40 @@
+// Command keygen creates and retires the JWT signing keys read from
+// JWT_KEY_DIR.
+//
+//	go run ./cmd/keygen -dir keys -alg EdDSA        # new key named after today's date
+//	go run ./cmd/keygen -dir keys -retire 2025-01-01 # keep only the public half
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"rytr/internal/keys"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", os.Getenv("JWT_KEY_DIR"), "key directory")
+	kid := flag.String("kid", time.Now().UTC().Format("2006-01-02"), "id of the new key")
+	alg := flag.String("alg", "EdDSA", "algorithm of the new key: EdDSA or RS256")
+	retire := flag.String("retire", "", "id of a key to turn into a verification-only key")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("-dir or JWT_KEY_DIR is required")
+	}
+	if *retire != "" {
+		if err := keys.Retire(*dir, *retire); err != nil {
+			log.Fatalf("failed to retire key: %v", err)
+		}
+		log.Printf("retired key %s", *retire)
+		return
+	}
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatalf("failed to create key directory: %v", err)
+	}
+	if err := keys.WriteNewKey(*dir, *kid, *alg); err != nil {
+		log.Fatalf("failed to create key: %v", err)
+	}
+	log.Printf("created %s key %s", *alg, *kid)
+}
@@ -0,0 +1,97 @@
+// Package avatar turns uploaded pictures into square avatars of a few
+// standard sizes.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Sizes are the widths, in pixels, of the square avatars Process renders.
+var Sizes = []int{64, 128, 256}
+
+// MaxDimension is the largest width or height of an accepted picture; it
+// keeps small files from decoding into huge images.
+const MaxDimension = 4096
+
+// ContentType is the type of the avatars rendered by Process.
+const ContentType = "image/png"
+
+var (
+	ErrUnsupportedType = errors.New("avatar: picture must be PNG, JPEG or WebP")
+	ErrTooLarge        = fmt.Errorf("avatar: picture must be at most %dx%d pixels", MaxDimension, MaxDimension)
+)
+
+var decoders = map[string]struct {
+	config func(r *bytes.Reader) (image.Config, error)
+	decode func(r *bytes.Reader) (image.Image, error)
+}{
+	"image/png": {
+		func(r *bytes.Reader) (image.Config, error) { return png.DecodeConfig(r) },
+		func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) },
+	},
+	"image/jpeg": {
+		func(r *bytes.Reader) (image.Config, error) { return jpeg.DecodeConfig(r) },
+		func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) },
+	},
+	"image/webp": {
+		func(r *bytes.Reader) (image.Config, error) { return webp.DecodeConfig(r) },
+		func(r *bytes.Reader) (image.Image, error) { return webp.Decode(r) },
+	},
+}
+
+// DetectType returns the type of a picture judging by its content, and
+// ErrUnsupportedType if it is not a PNG, JPEG or WebP image.
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := decoders[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// Process crops the center square of a picture and renders it as a PNG of
+// every size in Sizes, keyed by size.
+func Process(data []byte) (map[int][]byte, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+	d := decoders[contentType]
+	config, err := d.config(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("avatar: reading picture: %v", err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, ErrTooLarge
+	}
+	src, err := d.decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("avatar: decoding picture: %v", err)
+	}
+
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x, y := b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2
+	square := image.Rect(x, y, x+side, y+side)
+
+	result := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, square, draw.Src, nil)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, fmt.Errorf("avatar: encoding picture: %v", err)
+		}
+		result[size] = buf.Bytes()
+	}
+	return result, nil
+}
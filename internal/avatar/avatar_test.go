@@ -0,0 +1,59 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("error encoding png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 50, 30)), nil); err != nil {
+		t.Fatalf("error encoding jpeg: %v", err)
+	}
+	for name, data := range map[string][]byte{"png": encodePNG(t, 300, 200), "jpeg": jpg.Bytes()} {
+		avatars, err := Process(data)
+		if err != nil {
+			t.Fatalf("Process(%s) returned error: %v", name, err)
+		}
+		for _, size := range Sizes {
+			img, err := png.Decode(bytes.NewReader(avatars[size]))
+			if err != nil {
+				t.Fatalf("error decoding %s avatar of size %d: %v", name, size, err)
+			}
+			if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+				t.Errorf("expected %s avatar of %dx%d, got %v", name, size, size, b)
+			}
+		}
+	}
+}
+
+func TestProcessRefuses(t *testing.T) {
+	if _, err := Process([]byte("GIF89a not really")); err != ErrUnsupportedType {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+	if _, err := Process(encodePNG(t, MaxDimension+1, 1)); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := DetectType([]byte("RIFF\x00\x00\x00\x00WEBPVP8 ")); err != nil {
+		t.Errorf("expected WebP to be accepted, got %v", err)
+	}
+}
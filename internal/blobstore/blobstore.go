@@ -0,0 +1,69 @@
+// Package blobstore keeps files such as avatars in a local directory or an
+// S3 compatible bucket.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned for keys without a blob.
+var ErrNotFound = errors.New("blobstore: blob not found")
+
+// BlobStore stores blobs under slash separated keys such as
+// "avatars/<user>/<name>.png".
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of a blob.
+	URL(key string) string
+}
+
+// New builds a BlobStore from the environment. BLOB_DRIVER selects the
+// implementation: "local" (the default) or "s3". Blobs of stores that are
+// not public are served by the API under baseURL.
+func New(baseURL string) (BlobStore, error) {
+	switch driver := strings.ToLower(os.Getenv("BLOB_DRIVER")); driver {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "tmp/blobs"
+		}
+		return NewLocalStore(dir, baseURL), nil
+	case "s3":
+		store := &S3Store{
+			Endpoint:        strings.TrimSuffix(os.Getenv("S3_ENDPOINT"), "/"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       strings.TrimSuffix(os.Getenv("S3_PUBLIC_URL"), "/"),
+		}
+		if store.Endpoint == "" || store.Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required for BLOB_DRIVER=s3")
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		if store.PublicURL == "" {
+			store.PublicURL = baseURL
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown BLOB_DRIVER %q", driver)
+	}
+}
+
+// ValidKey reports whether key is a clean relative path that cannot escape
+// the store.
+func ValidKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../") && !strings.ContainsAny(key, "\\\x00")
+}
@@ -0,0 +1,7 @@
+package dto
+
+import "github.com/google/uuid"
+
+type CardColumn struct {
+	ColumnID uuid.UUID `json:"column_id"`
+}
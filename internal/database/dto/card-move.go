@@ -0,0 +1,11 @@
+package dto
+
+import "github.com/google/uuid"
+
+// CardMove places a card between two neighbors; a missing neighbor is the
+// start or end of the column.
+type CardMove struct {
+	ColumnID   *uuid.UUID `json:"column_id"`
+	PreviousID *uuid.UUID `json:"previous_id"`
+	NextID     *uuid.UUID `json:"next_id"`
+}
@@ -1,5 +0,0 @@
-package dto
-
-type CardStatus struct {
-	Status int8 `json:"status"`
-}
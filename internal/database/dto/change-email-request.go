@@ -0,0 +1,8 @@
+package dto
+
+type ChangeEmailRequest struct {
+	NewEmail     string `json:"new_email"`
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type CreateTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,5 @@
+package dto
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
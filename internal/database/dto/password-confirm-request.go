@@ -0,0 +1,9 @@
+package dto
+
+// PasswordConfirmRequest confirms a sensitive change. Users without a
+// password send a two-factor Code or RecoveryCode instead.
+type PasswordConfirmRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
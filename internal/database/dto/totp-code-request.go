@@ -0,0 +1,5 @@
+package dto
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
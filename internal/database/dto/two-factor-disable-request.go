@@ -0,0 +1,7 @@
+package dto
+
+type TwoFactorDisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_identities;
+
+-- Accounts created through an identity provider have no password to keep.
+DELETE FROM users WHERE password IS NULL;
+ALTER TABLE users ALTER COLUMN password SET NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS fk_session;
+
+DROP TABLE IF EXISTS sessions;
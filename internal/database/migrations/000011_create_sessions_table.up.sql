@@ -0,0 +1,28 @@
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    user_id UUID NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+-- Every refresh token family issued so far becomes a session.
+INSERT INTO sessions (id, user_id, expires_at, revoked_at, last_seen_at, created_at)
+SELECT
+    family_id,
+    user_id,
+    MAX(expires_at),
+    CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END,
+    MAX(created_at),
+    MIN(created_at)
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens
+ADD CONSTRAINT fk_session FOREIGN KEY (family_id) REFERENCES sessions (id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_notes_user_id;
+DROP INDEX IF EXISTS idx_cards_user_id;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS password_reset_required,
+DROP COLUMN IF EXISTS disabled_at,
+DROP COLUMN IF EXISTS role;
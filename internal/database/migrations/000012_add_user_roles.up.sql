@@ -0,0 +1,7 @@
+ALTER TABLE users
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_cards_user_id ON cards (user_id);
+CREATE INDEX idx_notes_user_id ON notes (user_id);
@@ -0,0 +1,16 @@
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    user_id UUID,
+    actor_id UUID,
+    event_type VARCHAR(64) NOT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_actor FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_audit_events_user_id ON audit_events (user_id, created_at DESC);
+CREATE INDEX idx_audit_events_event_type ON audit_events (event_type, created_at DESC);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at DESC);
@@ -0,0 +1,7 @@
+CREATE TABLE user_settings (
+    user_id UUID PRIMARY KEY,
+    version INT NOT NULL,
+    settings JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
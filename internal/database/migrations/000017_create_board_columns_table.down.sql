@@ -0,0 +1,28 @@
+ALTER TABLE cards ADD COLUMN status SMALLINT NOT NULL DEFAULT 0;
+
+-- Columns map back to statuses by their rank on the board.
+UPDATE cards SET status = ranked.rank
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY position, created_at) - 1 AS rank
+    FROM board_columns
+) AS ranked
+WHERE ranked.id = cards.column_id;
+
+ALTER TABLE cards ALTER COLUMN status DROP DEFAULT;
+
+UPDATE user_settings
+SET settings = settings || jsonb_build_object('default_card_status', ranked.rank)
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY position, created_at) - 1 AS rank
+    FROM board_columns
+) AS ranked
+WHERE ranked.id::TEXT = user_settings.settings ->> 'default_column_id';
+
+UPDATE user_settings
+SET version = 1, settings = settings - 'default_column_id'
+WHERE version >= 2;
+
+DROP INDEX IF EXISTS idx_cards_column_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS column_id;
+
+DROP TABLE IF EXISTS board_columns;
@@ -0,0 +1,53 @@
+CREATE TABLE board_columns (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    user_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    position INT NOT NULL,
+    is_done BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    legacy_status SMALLINT,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_board_columns_user_id ON board_columns (user_id, position);
+
+-- Every user starts with the three columns clients used statuses 0 to 2 for.
+INSERT INTO board_columns (user_id, name, position, is_done, legacy_status)
+SELECT users.id, defaults.name, defaults.status, defaults.status = 2, defaults.status
+FROM users
+CROSS JOIN (VALUES ('To do', 0), ('In progress', 1), ('Done', 2)) AS defaults (name, status);
+
+-- Any other status in use gets a column of its own.
+INSERT INTO board_columns (user_id, name, position, legacy_status)
+SELECT DISTINCT user_id, 'Status ' || status, status, status
+FROM cards
+WHERE status NOT IN (0, 1, 2);
+
+ALTER TABLE cards ADD COLUMN column_id UUID;
+
+UPDATE cards SET column_id = board_columns.id
+FROM board_columns
+WHERE board_columns.user_id = cards.user_id AND board_columns.legacy_status = cards.status;
+
+ALTER TABLE cards
+ALTER COLUMN column_id SET NOT NULL,
+ADD CONSTRAINT fk_column FOREIGN KEY (column_id) REFERENCES board_columns (id),
+DROP COLUMN status;
+
+-- Version 1 settings kept a default card status; version 2 keeps the column
+-- that status became. A status without a column falls back to the default.
+UPDATE user_settings
+SET settings = settings || jsonb_build_object('default_column_id', board_columns.id)
+FROM board_columns
+WHERE board_columns.user_id = user_settings.user_id
+AND board_columns.legacy_status = (user_settings.settings ->> 'default_card_status')::SMALLINT;
+
+UPDATE user_settings
+SET version = 2, settings = settings - 'default_card_status'
+WHERE version < 2;
+
+ALTER TABLE board_columns DROP COLUMN legacy_status;
+
+CREATE INDEX idx_cards_column_id ON cards (column_id);
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_cards_board_id;
+
+ALTER TABLE cards
+DROP CONSTRAINT IF EXISTS fk_column,
+DROP CONSTRAINT IF EXISTS fk_board,
+DROP COLUMN IF EXISTS board_id,
+ADD CONSTRAINT fk_column FOREIGN KEY (column_id) REFERENCES board_columns (id);
+
+DROP INDEX IF EXISTS idx_board_columns_board_id;
+CREATE INDEX idx_board_columns_user_id ON board_columns (user_id, position);
+
+ALTER TABLE board_columns
+DROP CONSTRAINT IF EXISTS uq_board_columns_board,
+DROP CONSTRAINT IF EXISTS fk_board,
+DROP COLUMN IF EXISTS board_id;
+
+DROP TABLE IF EXISTS boards;
@@ -0,0 +1,44 @@
+CREATE TABLE boards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    user_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_boards_user_id ON boards (user_id, created_at);
+
+-- The columns every user has so far become their first board.
+INSERT INTO boards (user_id, name)
+SELECT DISTINCT user_id, 'My board' FROM board_columns;
+
+ALTER TABLE board_columns ADD COLUMN board_id UUID;
+
+UPDATE board_columns SET board_id = boards.id
+FROM boards
+WHERE boards.user_id = board_columns.user_id;
+
+ALTER TABLE board_columns
+ALTER COLUMN board_id SET NOT NULL,
+ADD CONSTRAINT fk_board FOREIGN KEY (board_id) REFERENCES boards (id) ON DELETE CASCADE,
+ADD CONSTRAINT uq_board_columns_board UNIQUE (id, board_id);
+
+DROP INDEX IF EXISTS idx_board_columns_user_id;
+CREATE INDEX idx_board_columns_board_id ON board_columns (board_id, position);
+
+-- Cards keep the board of their column, which the foreign key enforces.
+ALTER TABLE cards ADD COLUMN board_id UUID;
+
+UPDATE cards SET board_id = board_columns.board_id
+FROM board_columns
+WHERE board_columns.id = cards.column_id;
+
+ALTER TABLE cards
+ALTER COLUMN board_id SET NOT NULL,
+DROP CONSTRAINT fk_column,
+ADD CONSTRAINT fk_board FOREIGN KEY (board_id) REFERENCES boards (id) ON DELETE CASCADE,
+ADD CONSTRAINT fk_column FOREIGN KEY (column_id, board_id) REFERENCES board_columns (id, board_id);
+
+CREATE INDEX idx_cards_board_id ON cards (board_id);
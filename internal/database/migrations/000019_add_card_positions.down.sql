@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_cards_column_position;
+
+ALTER TABLE cards DROP COLUMN IF EXISTS position;
@@ -0,0 +1,15 @@
+-- Positions are fractional keys compared byte by byte, see internal/rank.
+ALTER TABLE cards ADD COLUMN position TEXT COLLATE "C";
+
+-- Existing cards keep the order they were created in. The keys are decimal
+-- numbers of equal width followed by "V" because keys may not end in "0".
+UPDATE cards SET position = ranked.position
+FROM (
+    SELECT id, LPAD(ROW_NUMBER() OVER (PARTITION BY column_id ORDER BY created_at, id)::TEXT, 8, '0') || 'V' AS position
+    FROM cards
+) AS ranked
+WHERE ranked.id = cards.id;
+
+ALTER TABLE cards ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX idx_cards_column_position ON cards (column_id, position);
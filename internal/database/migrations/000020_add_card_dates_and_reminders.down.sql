@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS card_reminders;
+
+DROP INDEX IF EXISTS idx_cards_due_at;
+
+ALTER TABLE cards
+DROP CONSTRAINT IF EXISTS chk_cards_dates,
+DROP COLUMN IF EXISTS due_at,
+DROP COLUMN IF EXISTS starts_at;
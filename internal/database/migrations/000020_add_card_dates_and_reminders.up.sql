@@ -0,0 +1,21 @@
+ALTER TABLE cards
+ADD COLUMN starts_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN due_at TIMESTAMP WITH TIME ZONE,
+ADD CONSTRAINT chk_cards_dates CHECK (starts_at IS NULL OR due_at IS NULL OR starts_at <= due_at);
+
+CREATE INDEX idx_cards_due_at ON cards (user_id, due_at) WHERE due_at IS NOT NULL;
+
+-- A reminder fires minutes_before the due date of its card. sent_for is the
+-- due date it last fired for, so moving the due date arms it again.
+CREATE TABLE card_reminders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    card_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    minutes_before INTEGER NOT NULL CHECK (minutes_before >= 0),
+    sent_for TIMESTAMP WITH TIME ZONE,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_card FOREIGN KEY (card_id) REFERENCES cards (id) ON DELETE CASCADE,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT uq_card_reminders UNIQUE (card_id, minutes_before)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS note_labels;
+
+DROP TABLE IF EXISTS card_labels;
+
+DROP TABLE IF EXISTS labels;
@@ -0,0 +1,32 @@
+CREATE TABLE labels (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4 (),
+    user_id UUID NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Label names are unique per user regardless of case.
+CREATE UNIQUE INDEX uq_labels_user_name ON labels (user_id, LOWER(name));
+
+CREATE TABLE card_labels (
+    card_id UUID NOT NULL,
+    label_id UUID NOT NULL,
+    PRIMARY KEY (card_id, label_id),
+    CONSTRAINT fk_card FOREIGN KEY (card_id) REFERENCES cards (id) ON DELETE CASCADE,
+    CONSTRAINT fk_label FOREIGN KEY (label_id) REFERENCES labels (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_card_labels_label_id ON card_labels (label_id);
+
+CREATE TABLE note_labels (
+    note_id UUID NOT NULL,
+    label_id UUID NOT NULL,
+    PRIMARY KEY (note_id, label_id),
+    CONSTRAINT fk_note FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE CASCADE,
+    CONSTRAINT fk_label FOREIGN KEY (label_id) REFERENCES labels (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_note_labels_label_id ON note_labels (label_id);
@@ -0,0 +1,4 @@
+ALTER TABLE audit_events DROP CONSTRAINT fk_user;
+
+ALTER TABLE audit_events
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+-- Keep the audit trail of deleted accounts; the events carry the user's ID
+-- and email in their metadata.
+ALTER TABLE audit_events DROP CONSTRAINT fk_user;
+
+ALTER TABLE audit_events
+    ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
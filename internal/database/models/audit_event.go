@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of audit events.
+const (
+	AuditRegistered               = "registered"
+	AuditLogin                    = "login"
+	AuditLoginFailed              = "login_failed"
+	AuditLogout                   = "logout"
+	AuditLogoutAll                = "logout_all"
+	AuditSessionRevoked           = "session_revoked"
+	AuditPasswordChanged          = "password_changed"
+	AuditPasswordReset            = "password_reset"
+	AuditProfileUpdated           = "profile_updated"
+	AuditSettingsUpdated          = "settings_updated"
+	AuditAvatarUpdated            = "avatar_updated"
+	AuditAvatarDeleted            = "avatar_deleted"
+	AuditEmailChangeRequested     = "email_change_requested"
+	AuditEmailChanged             = "email_changed"
+	AuditEmailChangeCancelled     = "email_change_cancelled"
+	AuditTOTPEnabled              = "totp_enabled"
+	AuditTOTPDisabled             = "totp_disabled"
+	AuditRecoveryCodesRegenerated = "recovery_codes_regenerated"
+	AuditTokenCreated             = "token_created"
+	AuditTokenRevoked             = "token_revoked"
+	AuditIdentityLinked           = "identity_linked"
+	AuditIdentityUnlinked         = "identity_unlinked"
+	AuditDeletionScheduled        = "deletion_scheduled"
+	AuditExportRequested          = "export_requested"
+	AuditDataImported             = "data_imported"
+	AuditUserDisabled             = "user_disabled"
+	AuditUserEnabled              = "user_enabled"
+	AuditRoleChanged              = "role_changed"
+	AuditPasswordResetForced      = "password_reset_forced"
+)
+
+// AuditEvent records something that happened to an account. UserID is the
+// account concerned and is nil for failed logins with an unknown address;
+// ActorID is set when someone else, such as an admin, acted on the account.
+type AuditEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    *uuid.UUID      `json:"user_id"`
+	ActorID   *uuid.UUID      `json:"actor_id"`
+	Type      string          `json:"type"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	Metadata  json.RawMessage `json:"metadata"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultBoardName is the name of the board every user starts with.
+const DefaultBoardName = "My board"
+
+// Board groups columns and their cards, such as "Work" or one per project.
+// Archived boards are hidden from card lists until they are restored.
+type Board struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Name       string     `json:"name"`
+	ArchivedAt *time.Time `json:"archived_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
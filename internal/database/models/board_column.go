@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BoardColumn is a column of a board cards are sorted into, such as "In
+// progress". Cards in columns marked Done are finished.
+type BoardColumn struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"-"`
+	BoardID   uuid.UUID `json:"board_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Position  int       `json:"position"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultBoardColumns are the columns of a new board.
+func DefaultBoardColumns() []BoardColumn {
+	return []BoardColumn{
+		{Name: "To do", Position: 0},
+		{Name: "In progress", Position: 1},
+		{Name: "Done", Position: 2, Done: true},
+	}
+}
@@ -10,8 +10,14 @@ type Card struct {
 	ID          uuid.UUID `json:"id"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
-	Status      int8      `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	UserID      uuid.UUID `json:"user_id"`
+	BoardID     uuid.UUID `json:"board_id"`
+	ColumnID    uuid.UUID `json:"column_id"`
+	// Position orders the cards of a column, see the rank package.
+	Position  string      `json:"position"`
+	StartsAt  *time.Time  `json:"starts_at"`
+	DueAt     *time.Time  `json:"due_at"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	UserID    uuid.UUID   `json:"user_id"`
+	LabelIDs  []uuid.UUID `json:"label_ids"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CardReminder asks for a notification MinutesBefore the due date of a card.
+type CardReminder struct {
+	ID            uuid.UUID  `json:"id"`
+	CardID        uuid.UUID  `json:"card_id"`
+	UserID        uuid.UUID  `json:"-"`
+	MinutesBefore int        `json:"minutes_before"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// DueReminder is a reminder whose time has come, with what is needed to
+// notify its user.
+type DueReminder struct {
+	CardReminder
+	CardTitle string
+	DueAt     time.Time
+	Email     string
+	FirstName string
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// States of a DataExport.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is an archive of a user's data that is built in the background
+// and can be downloaded until ExpiresAt.
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"-"`
+	Status      string     `json:"status"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Error       string     `json:"-"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
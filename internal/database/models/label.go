@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Label categorizes cards and notes of its user.
+type Label struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"-"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LabelCount is a label with the number of cards and notes it is on, as
+// shown in sidebars. Cards on archived boards are not counted.
+type LabelCount struct {
+	Label
+	CardCount int `json:"card_count"`
+	NoteCount int `json:"note_count"`
+}
@@ -7,10 +7,11 @@ import (
 )
 
 type Note struct {
-	ID        uuid.UUID `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID   `json:"id"`
+	Title     string      `json:"title"`
+	Content   string      `json:"content"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	UserID    uuid.UUID   `json:"user_id"`
+	LabelIDs  []uuid.UUID `json:"label_ids"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes a personal access token can be granted.
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+	ScopeCardsRead    = "cards:read"
+	ScopeCardsWrite   = "cards:write"
+	ScopeNotesRead    = "notes:read"
+	ScopeNotesWrite   = "notes:write"
+	ScopeAI           = "ai"
+)
+
+var Scopes = []string{
+	ScopeProfileRead,
+	ScopeProfileWrite,
+	ScopeCardsRead,
+	ScopeCardsWrite,
+	ScopeNotesRead,
+	ScopeNotesWrite,
+	ScopeAI,
+}
+
+// PersonalAccessToken is a long-lived, scoped credential for scripts. Only
+// the hash of the token is stored; Prefix is kept so users can tell their
+// tokens apart.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
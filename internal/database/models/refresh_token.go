@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a single link in a rotation chain. Every token issued from
+// the same login shares a FamilyID so that replaying a rotated token can
+// revoke the whole chain.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -6,12 +6,35 @@ import (
 	"github.com/google/uuid"
 )
 
+// Roles a user can have.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uuid.UUID    `json:"id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	ID                    uuid.UUID  `json:"id"`
+	FirstName             string     `json:"first_name"`
+	LastName              string     `json:"last_name"`
+	Email                 string     `json:"email"`
+	Password              string     `json:"-"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"`
+	DeletionScheduledAt   *time.Time `json:"deletion_scheduled_at"`
+	TOTPSecret            string     `json:"-"`
+	TOTPEnabledAt         *time.Time `json:"totp_enabled_at"`
+	Role                  string     `json:"role"`
+	DisabledAt            *time.Time `json:"disabled_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	// AvatarKey is the common prefix of the blobs of the avatar, or empty.
+	AvatarKey string    `json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// UserSummary is a user together with the amount of data they own, as shown
+// in the admin API.
+type UserSummary struct {
+	User
+	CardCount int `json:"card_count"`
+	NoteCount int `json:"note_count"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SettingsVersion is the version of the UserSettings format. Settings added
+// by a version start out with their default when older settings are read;
+// settings that change meaning are converted by the migration that changes
+// them. Version 2 replaced the default card status with the column the
+// status became, see migration 000017.
+const SettingsVersion = 2
+
+// Themes a client can be asked to use.
+const (
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+// Days a week can start on.
+const (
+	WeekStartMonday = "monday"
+	WeekStartSunday = "sunday"
+)
+
+// UserSettings are the preferences of a user that follow them across
+// devices.
+type UserSettings struct {
+	Version         int        `json:"version"`
+	Timezone        string     `json:"timezone"`
+	DefaultColumnID *uuid.UUID `json:"default_column_id"`
+	Theme           string     `json:"theme"`
+	WeekStart       string     `json:"week_start"`
+	AIEnabled       bool       `json:"ai_enabled"`
+	UpdatedAt       *time.Time `json:"updated_at"`
+}
+
+// DefaultUserSettings returns the settings of a user who never changed any.
+func DefaultUserSettings() UserSettings {
+	return UserSettings{
+		Version:   SettingsVersion,
+		Timezone:  "UTC",
+		Theme:     ThemeSystem,
+		WeekStart: WeekStartMonday,
+		AIEnabled: true,
+	}
+}
+
+// Location returns the time zone of the user, or UTC if it is unknown.
+func (s *UserSettings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FirstWeekday returns the day the user's week starts on.
+func (s *UserSettings) FirstWeekday() time.Weekday {
+	if s.WeekStart == WeekStartSunday {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// Today returns the start of the day of now in the time zone of the user
+// and the start of the next day.
+func (s *UserSettings) Today(now time.Time) (time.Time, time.Time) {
+	loc := s.Location()
+	year, month, day := now.In(loc).Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// ThisWeek returns the start of the week of now, which begins on the
+// FirstWeekday of the user, and the start of the next week.
+func (s *UserSettings) ThisWeek(now time.Time) (time.Time, time.Time) {
+	today, _ := s.Today(now)
+	start := today.AddDate(0, 0, -((int(today.Weekday()) - int(s.FirstWeekday()) + 7) % 7))
+	return start, start.AddDate(0, 0, 7)
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q) returned error: %v", name, err)
+	}
+	return loc
+}
+
+func TestToday(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	kolkata := mustLoad(t, "Asia/Kolkata")
+	newYork := mustLoad(t, "America/New_York")
+	tests := []struct {
+		name     string
+		timezone string
+		now      time.Time
+		start    time.Time
+		length   time.Duration
+	}{
+		{"berlin before midnight", "Europe/Berlin", time.Date(2026, 3, 10, 22, 59, 59, 0, time.UTC), time.Date(2026, 3, 10, 0, 0, 0, 0, berlin), 24 * time.Hour},
+		{"berlin at midnight", "Europe/Berlin", time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC), time.Date(2026, 3, 11, 0, 0, 0, 0, berlin), 24 * time.Hour},
+		{"kolkata before midnight", "Asia/Kolkata", time.Date(2026, 3, 10, 18, 29, 59, 0, time.UTC), time.Date(2026, 3, 10, 0, 0, 0, 0, kolkata), 24 * time.Hour},
+		{"kolkata at midnight", "Asia/Kolkata", time.Date(2026, 3, 10, 18, 30, 0, 0, time.UTC), time.Date(2026, 3, 11, 0, 0, 0, 0, kolkata), 24 * time.Hour},
+		{"new york is behind utc", "America/New_York", time.Date(2026, 3, 11, 3, 0, 0, 0, time.UTC), time.Date(2026, 3, 10, 0, 0, 0, 0, newYork), 24 * time.Hour},
+		{"daylight saving starts", "America/New_York", time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), 23 * time.Hour},
+		{"daylight saving ends", "America/New_York", time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), 25 * time.Hour},
+		{"unknown time zone", "Mars/Olympus", time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC), time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		settings := UserSettings{Timezone: tt.timezone}
+		start, end := settings.Today(tt.now)
+		if !start.Equal(tt.start) {
+			t.Errorf("%s: start = %v, want %v", tt.name, start, tt.start)
+		}
+		if end.Sub(start) != tt.length {
+			t.Errorf("%s: day is %v long, want %v", tt.name, end.Sub(start), tt.length)
+		}
+	}
+}
+
+func TestThisWeek(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	newYork := mustLoad(t, "America/New_York")
+	// 2026-03-09 is a Monday and 2026-03-08 a Sunday.
+	tests := []struct {
+		name      string
+		timezone  string
+		weekStart string
+		now       time.Time
+		start     time.Time
+		length    time.Duration
+	}{
+		{"monday on the first day", "Europe/Berlin", WeekStartMonday, time.Date(2026, 3, 9, 0, 0, 0, 0, berlin), time.Date(2026, 3, 9, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"monday on the last day", "Europe/Berlin", WeekStartMonday, time.Date(2026, 3, 15, 23, 59, 59, 0, berlin), time.Date(2026, 3, 9, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"monday just before the week", "Europe/Berlin", WeekStartMonday, time.Date(2026, 3, 8, 23, 59, 59, 0, berlin), time.Date(2026, 3, 2, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"sunday on the first day", "Europe/Berlin", WeekStartSunday, time.Date(2026, 3, 8, 0, 0, 0, 0, berlin), time.Date(2026, 3, 8, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"sunday on the last day", "Europe/Berlin", WeekStartSunday, time.Date(2026, 3, 14, 23, 59, 59, 0, berlin), time.Date(2026, 3, 8, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"default is monday", "Europe/Berlin", "", time.Date(2026, 3, 8, 12, 0, 0, 0, berlin), time.Date(2026, 3, 2, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"local day differs from utc", "Europe/Berlin", WeekStartMonday, time.Date(2026, 3, 8, 23, 30, 0, 0, time.UTC), time.Date(2026, 3, 9, 0, 0, 0, 0, berlin), 7 * 24 * time.Hour},
+		{"daylight saving starts", "America/New_York", WeekStartSunday, time.Date(2026, 3, 10, 12, 0, 0, 0, newYork), time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), 7*24*time.Hour - time.Hour},
+		{"daylight saving ends", "America/New_York", WeekStartMonday, time.Date(2026, 11, 1, 12, 0, 0, 0, newYork), time.Date(2026, 10, 26, 0, 0, 0, 0, newYork), 7*24*time.Hour + time.Hour},
+	}
+	for _, tt := range tests {
+		settings := UserSettings{Timezone: tt.timezone, WeekStart: tt.weekStart}
+		start, end := settings.ThisWeek(tt.now)
+		if !start.Equal(tt.start) {
+			t.Errorf("%s: start = %v, want %v", tt.name, start, tt.start)
+		}
+		if end.Sub(start) != tt.length {
+			t.Errorf("%s: week is %v long, want %v", tt.name, end.Sub(start), tt.length)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	// Email change tokens carry the new address as payload. The confirm
+	// token goes to the new address, the cancel token to the old one.
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeEmailChangeCancel = "email_change_cancel"
+)
+
+// UserToken is a hashed, single-use token mailed to a user, e.g. to verify
+// an email address. Payload carries purpose specific data.
+type UserToken struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	Payload   string     `json:"payload"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
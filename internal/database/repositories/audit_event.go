@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"rytr/internal/database/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEventFilter narrows a search of audit events. Zero fields match
+// everything.
+type AuditEventFilter struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+	Type    string
+	IP      string
+	From    time.Time
+	To      time.Time
+}
+
+type AuditEventRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// Search lists matching events, newest first, and returns the total
+	// number of matches.
+	Search(ctx context.Context, filter AuditEventFilter, limit, offset int) (*[]models.AuditEvent, int, error)
+}
+
+type auditEventRepository struct {
+	db *sql.DB
+}
+
+func NewAuditEventRepository(db *sql.DB) AuditEventRepository {
+	return &auditEventRepository{db: db}
+}
+
+func (r *auditEventRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	metadata := event.Metadata
+	if len(metadata) == 0 {
+		metadata = []byte("{}")
+	}
+	query := `
+		INSERT INTO audit_events (user_id, actor_id, event_type, ip, user_agent, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, event.UserID, event.ActorID, event.Type, event.IP, event.UserAgent, string(metadata)).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating audit event: %v", err)
+	}
+	return nil
+}
+
+// auditEventQuery builds the query of Search, which also counts all
+// matching events.
+func auditEventQuery(filter AuditEventFilter, limit, offset int) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.UserID != uuid.Nil {
+		where("user_id = $%d", filter.UserID)
+	}
+	if filter.ActorID != uuid.Nil {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Type != "" {
+		where("event_type = $%d", filter.Type)
+	}
+	if filter.IP != "" {
+		where("ip = $%d", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < $%d", filter.To)
+	}
+	query := `SELECT id, user_id, actor_id, event_type, ip, user_agent, metadata, created_at, COUNT(*) OVER ()
+		FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+	return query, args
+}
+
+func (r *auditEventRepository) Search(ctx context.Context, filter AuditEventFilter, limit, offset int) (*[]models.AuditEvent, int, error) {
+	query, args := auditEventQuery(filter, limit, offset)
+	result, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying audit events: %v", err)
+	}
+	defer result.Close()
+	events := []models.AuditEvent{}
+	total := 0
+	for result.Next() {
+		event := models.AuditEvent{}
+		var metadata []byte
+		err := result.Scan(
+			&event.ID,
+			&event.UserID,
+			&event.ActorID,
+			&event.Type,
+			&event.IP,
+			&event.UserAgent,
+			&metadata,
+			&event.CreatedAt,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning audit event: %v", err)
+		}
+		event.Metadata = metadata
+		events = append(events, event)
+	}
+	if err = result.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating audit events: %v", err)
+	}
+	return &events, total, nil
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAuditEventQuery(t *testing.T) {
+	userID := uuid.New()
+	actorID := uuid.New()
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name   string
+		filter AuditEventFilter
+		where  string
+		args   []any
+	}{
+		{
+			name:   "no filter",
+			filter: AuditEventFilter{},
+			where:  "",
+			args:   []any{50, 0},
+		},
+		{
+			name:   "user and type",
+			filter: AuditEventFilter{UserID: userID, Type: "login"},
+			where:  " WHERE user_id = $1 AND event_type = $2",
+			args:   []any{userID, "login", 50, 0},
+		},
+		{
+			name:   "every filter",
+			filter: AuditEventFilter{UserID: userID, ActorID: actorID, Type: "login", IP: "10.0.0.1", From: from, To: to},
+			where:  " WHERE user_id = $1 AND actor_id = $2 AND event_type = $3 AND ip = $4 AND created_at >= $5 AND created_at < $6",
+			args:   []any{userID, actorID, "login", "10.0.0.1", from, to, 50, 0},
+		},
+		{
+			name:   "time range only",
+			filter: AuditEventFilter{To: to},
+			where:  " WHERE created_at < $1",
+			args:   []any{to, 50, 0},
+		},
+	}
+	for _, tt := range tests {
+		query, args := auditEventQuery(tt.filter, 50, 0)
+		if !strings.HasSuffix(query, "FROM audit_events"+tt.where+" ORDER BY created_at DESC LIMIT $"+strconv.Itoa(len(tt.args)-1)+" OFFSET $"+strconv.Itoa(len(tt.args))) {
+			t.Errorf("%s: unexpected query %q", tt.name, query)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.args)
+		}
+	}
+}
+
+func TestAuditEventQueryPage(t *testing.T) {
+	query, args := auditEventQuery(AuditEventFilter{IP: "10.0.0.1"}, 20, 40)
+	if !strings.HasSuffix(query, "WHERE ip = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3") {
+		t.Errorf("unexpected query %q", query)
+	}
+	if !reflect.DeepEqual(args, []any{"10.0.0.1", 20, 40}) {
+		t.Errorf("args = %v, want the IP, limit and offset", args)
+	}
+	if !strings.Contains(query, "COUNT(*) OVER ()") {
+		t.Errorf("expected the query to count every match, got %q", query)
+	}
+}
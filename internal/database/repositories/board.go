@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrBoardNotFound = errors.New("board not found")
+
+type BoardRepository interface {
+	// EnsureDefault gives a user without boards one with the default
+	// columns.
+	EnsureDefault(ctx context.Context, userID uuid.UUID) error
+	// GetAll returns the boards of a user, oldest first.
+	GetAll(ctx context.Context, userID uuid.UUID, includeArchived bool) (*[]models.Board, error)
+	GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Board, error)
+	// GetDefault returns the oldest board of a user that is not archived.
+	GetDefault(ctx context.Context, userID uuid.UUID) (*models.Board, error)
+	// Create adds a board together with the given columns.
+	Create(ctx context.Context, board *models.Board, columns []models.BoardColumn) error
+	Rename(ctx context.Context, board *models.Board) error
+	SetArchived(ctx context.Context, id uuid.UUID, userID uuid.UUID, archived bool) error
+	// Delete removes a board with its columns and cards.
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	DeleteAll(ctx context.Context, userID uuid.UUID) error
+}
+
+type boardRepository struct {
+	db DBTX
+}
+
+func NewBoardRepository(db DBTX) BoardRepository {
+	return &boardRepository{db: db}
+}
+
+const boardColumns = `id, user_id, name, archived_at, created_at, updated_at`
+
+func scanBoard(row rowScanner) (*models.Board, error) {
+	board := models.Board{}
+	err := row.Scan(
+		&board.ID,
+		&board.UserID,
+		&board.Name,
+		&board.ArchivedAt,
+		&board.CreatedAt,
+		&board.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// insertBoard inserts a board and its columns in one statement. The board
+// is only created if condition, a SQL expression, holds.
+func (r *boardRepository) insertBoard(ctx context.Context, board *models.Board, columns []models.BoardColumn, condition string) (bool, error) {
+	names := []string{}
+	colors := []string{}
+	positions := []int32{}
+	done := []bool{}
+	for _, column := range columns {
+		names = append(names, column.Name)
+		colors = append(colors, column.Color)
+		positions = append(positions, int32(column.Position))
+		done = append(done, column.Done)
+	}
+	query := `
+		WITH board AS (
+			INSERT INTO boards (user_id, name)
+			SELECT $1, $2 WHERE ` + condition + `
+			RETURNING ` + boardColumns + `
+		), inserted_columns AS (
+			INSERT INTO board_columns (user_id, board_id, name, color, position, is_done)
+			SELECT $1, board.id, c.name, c.color, c.position, c.is_done
+			FROM board, unnest($3::text[], $4::text[], $5::int[], $6::boolean[]) AS c (name, color, position, is_done)
+		)
+		SELECT ` + boardColumns + ` FROM board`
+	created, err := scanBoard(r.db.QueryRowContext(ctx, query, board.UserID, board.Name, names, colors, positions, done))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error creating board: %v", err)
+	}
+	*board = *created
+	return true, nil
+}
+
+func (r *boardRepository) EnsureDefault(ctx context.Context, userID uuid.UUID) error {
+	board := &models.Board{UserID: userID, Name: models.DefaultBoardName}
+	_, err := r.insertBoard(ctx, board, models.DefaultBoardColumns(), `NOT EXISTS (SELECT 1 FROM boards WHERE user_id = $1)`)
+	return err
+}
+
+func (r *boardRepository) Create(ctx context.Context, board *models.Board, columns []models.BoardColumn) error {
+	_, err := r.insertBoard(ctx, board, columns, `TRUE`)
+	return err
+}
+
+func (r *boardRepository) GetAll(ctx context.Context, userID uuid.UUID, includeArchived bool) (*[]models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards WHERE user_id = $1 AND ($2 OR archived_at IS NULL) ORDER BY created_at`
+	result, err := r.db.QueryContext(ctx, query, userID, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("error querying boards: %v", err)
+	}
+	defer result.Close()
+	boards := []models.Board{}
+	for result.Next() {
+		board, err := scanBoard(result)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning board: %v", err)
+		}
+		boards = append(boards, *board)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating boards: %v", err)
+	}
+	return &boards, nil
+}
+
+func (r *boardRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards WHERE id = $1 AND user_id = $2`
+	board, err := scanBoard(r.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrBoardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting board: %v", err)
+	}
+	return board, nil
+}
+
+func (r *boardRepository) GetDefault(ctx context.Context, userID uuid.UUID) (*models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards WHERE user_id = $1 AND archived_at IS NULL ORDER BY created_at LIMIT 1`
+	board, err := scanBoard(r.db.QueryRowContext(ctx, query, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrBoardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting board: %v", err)
+	}
+	return board, nil
+}
+
+func (r *boardRepository) Rename(ctx context.Context, board *models.Board) error {
+	query := `UPDATE boards SET name = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND user_id = $3 RETURNING updated_at`
+	err := r.db.QueryRowContext(ctx, query, board.Name, board.ID, board.UserID).Scan(&board.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrBoardNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error updating board: %v", err)
+	}
+	return nil
+}
+
+func (r *boardRepository) SetArchived(ctx context.Context, id uuid.UUID, userID uuid.UUID, archived bool) error {
+	query := `UPDATE boards SET archived_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2`
+	if archived {
+		query = `UPDATE boards SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2`
+	}
+	return r.execOne(ctx, "error updating board", query, id, userID)
+}
+
+func (r *boardRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	return r.execOne(ctx, "error deleting board", `DELETE FROM boards WHERE id = $1 AND user_id = $2`, id, userID)
+}
+
+func (r *boardRepository) DeleteAll(ctx context.Context, userID uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM boards WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting boards: %v", err)
+	}
+	return nil
+}
+
+// execOne runs a statement on a single board and reports a missing board
+// as ErrBoardNotFound.
+func (r *boardRepository) execOne(ctx context.Context, msg string, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %v", msg, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrBoardNotFound
+	}
+	return nil
+}
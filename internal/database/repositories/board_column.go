@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"rytr/internal/rank"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrColumnNotFound = errors.New("column not found")
+	// ErrColumnNotEmpty is returned when deleting a column that still has
+	// cards without saying where they should go.
+	ErrColumnNotEmpty = errors.New("column has cards")
+)
+
+type BoardColumnRepository interface {
+	// GetAll returns the columns of a board in order.
+	GetAll(ctx context.Context, boardID uuid.UUID, userID uuid.UUID) (*[]models.BoardColumn, error)
+	// GetAllForUser returns the columns of every board of a user.
+	GetAllForUser(ctx context.Context, userID uuid.UUID) (*[]models.BoardColumn, error)
+	GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.BoardColumn, error)
+	// Create adds a column after the last one of its board. It returns
+	// ErrBoardNotFound if the board does not belong to the user.
+	Create(ctx context.Context, column *models.BoardColumn) error
+	Update(ctx context.Context, column *models.BoardColumn) error
+	// Reorder moves every column in ids to its index in ids.
+	Reorder(ctx context.Context, boardID uuid.UUID, userID uuid.UUID, ids []uuid.UUID) error
+	// Delete removes a column after moving its cards to moveTo, which must
+	// be on the same board, behind the cards already there. Without moveTo
+	// it returns ErrColumnNotEmpty if the column has cards. It reads before
+	// it writes, so it should run in a transaction.
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID, moveTo *uuid.UUID) error
+}
+
+type boardColumnRepository struct {
+	db DBTX
+}
+
+func NewBoardColumnRepository(db DBTX) BoardColumnRepository {
+	return &boardColumnRepository{db: db}
+}
+
+const boardColumnColumns = `id, user_id, board_id, name, color, position, is_done, created_at, updated_at`
+
+func scanBoardColumn(row rowScanner) (*models.BoardColumn, error) {
+	column := models.BoardColumn{}
+	err := row.Scan(
+		&column.ID,
+		&column.UserID,
+		&column.BoardID,
+		&column.Name,
+		&column.Color,
+		&column.Position,
+		&column.Done,
+		&column.CreatedAt,
+		&column.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &column, nil
+}
+
+func (r *boardColumnRepository) GetAll(ctx context.Context, boardID uuid.UUID, userID uuid.UUID) (*[]models.BoardColumn, error) {
+	query := `SELECT ` + boardColumnColumns + ` FROM board_columns WHERE board_id = $1 AND user_id = $2 ORDER BY position, created_at`
+	return r.queryColumns(ctx, query, boardID, userID)
+}
+
+func (r *boardColumnRepository) GetAllForUser(ctx context.Context, userID uuid.UUID) (*[]models.BoardColumn, error) {
+	query := `SELECT ` + boardColumnColumns + ` FROM board_columns WHERE user_id = $1 ORDER BY board_id, position, created_at`
+	return r.queryColumns(ctx, query, userID)
+}
+
+func (r *boardColumnRepository) queryColumns(ctx context.Context, query string, args ...any) (*[]models.BoardColumn, error) {
+	result, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying columns: %v", err)
+	}
+	defer result.Close()
+	columns := []models.BoardColumn{}
+	for result.Next() {
+		column, err := scanBoardColumn(result)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning column: %v", err)
+		}
+		columns = append(columns, *column)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating columns: %v", err)
+	}
+	return &columns, nil
+}
+
+func (r *boardColumnRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.BoardColumn, error) {
+	query := `SELECT ` + boardColumnColumns + ` FROM board_columns WHERE id = $1 AND user_id = $2`
+	column, err := scanBoardColumn(r.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrColumnNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting column: %v", err)
+	}
+	return column, nil
+}
+
+func (r *boardColumnRepository) Create(ctx context.Context, column *models.BoardColumn) error {
+	query := `
+		INSERT INTO board_columns (user_id, board_id, name, color, position, is_done)
+		SELECT $1, $2, $3, $4, (SELECT COALESCE(MAX(position) + 1, 0) FROM board_columns WHERE board_id = $2), $5
+		WHERE EXISTS (SELECT 1 FROM boards WHERE id = $2 AND user_id = $1)
+		RETURNING id, position, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, column.UserID, column.BoardID, column.Name, column.Color, column.Done).
+		Scan(&column.ID, &column.Position, &column.CreatedAt, &column.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrBoardNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error creating column: %v", err)
+	}
+	return nil
+}
+
+func (r *boardColumnRepository) Update(ctx context.Context, column *models.BoardColumn) error {
+	query := `
+		UPDATE board_columns SET name = $1, color = $2, is_done = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND user_id = $5
+		RETURNING board_id, position, updated_at`
+	err := r.db.QueryRowContext(ctx, query, column.Name, column.Color, column.Done, column.ID, column.UserID).
+		Scan(&column.BoardID, &column.Position, &column.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrColumnNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error updating column: %v", err)
+	}
+	return nil
+}
+
+func (r *boardColumnRepository) Reorder(ctx context.Context, boardID uuid.UUID, userID uuid.UUID, ids []uuid.UUID) error {
+	order := make([]string, len(ids))
+	for i, id := range ids {
+		order[i] = id.String()
+	}
+	query := `
+		UPDATE board_columns SET position = ordered.position - 1, updated_at = CURRENT_TIMESTAMP
+		FROM unnest($1::uuid[]) WITH ORDINALITY AS ordered (id, position)
+		WHERE board_columns.id = ordered.id AND board_columns.board_id = $2 AND board_columns.user_id = $3`
+	if _, err := r.db.ExecContext(ctx, query, order, boardID, userID); err != nil {
+		return fmt.Errorf("error reordering columns: %v", err)
+	}
+	return nil
+}
+
+func (r *boardColumnRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID, moveTo *uuid.UUID) error {
+	var ids, positions []string
+	if moveTo != nil {
+		var err error
+		if ids, err = r.mergedCardOrder(ctx, id, *moveTo, userID); err != nil {
+			return err
+		}
+		positions = rank.Spread(len(ids))
+	}
+	// The cards are moved in the same statement, so a failed delete keeps
+	// them where they were. They go after the cards of moveTo, and all of
+	// them get fresh positions since the keys of two columns interleave.
+	query := `
+		WITH moved AS (
+			UPDATE cards SET column_id = $3, position = p.position,
+				updated_at = CASE WHEN cards.column_id = $1 THEN CURRENT_TIMESTAMP ELSE cards.updated_at END
+			FROM unnest($4::uuid[], $5::text[]) AS p (id, position)
+			WHERE cards.id = p.id AND cards.user_id = $2
+				AND EXISTS (SELECT 1 FROM board_columns WHERE id = $3 AND user_id = $2)
+		)
+		DELETE FROM board_columns WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID, moveTo, ids, positions)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrColumnNotEmpty
+		}
+		return fmt.Errorf("error deleting column: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrColumnNotFound
+	}
+	return nil
+}
+
+// mergedCardOrder returns the cards of moveTo in order followed by those of
+// the column id in order.
+func (r *boardColumnRepository) mergedCardOrder(ctx context.Context, id uuid.UUID, moveTo uuid.UUID, userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT id FROM cards
+		WHERE column_id IN ($1, $2) AND user_id = $3
+		ORDER BY column_id = $1, position, id`
+	rows, err := r.db.QueryContext(ctx, query, id, moveTo, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying card positions: %v", err)
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var cardID uuid.UUID
+		if err := rows.Scan(&cardID); err != nil {
+			return nil, fmt.Errorf("error scanning card position: %v", err)
+		}
+		ids = append(ids, cardID.String())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating card positions: %v", err)
+	}
+	return ids, nil
+}
@@ -6,123 +6,205 @@ import (
 	"errors"
 	"fmt"
 	"rytr/internal/database/models"
+	"rytr/internal/rank"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrInvalidNeighbors is returned by Move if the neighbors are not other
+// cards of the target column in order.
+var ErrInvalidNeighbors = errors.New("invalid neighbors")
+
+// CardFilter narrows the cards returned by List. Without a BoardID the
+// cards of every board that is not archived are listed.
+type CardFilter struct {
+	BoardID *uuid.UUID
+	// Pending limits the list to cards that are not in a done column.
+	Pending bool
+	// DueFrom and DueBefore limit the list to cards due in that range,
+	// which are then sorted by due date.
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	// Labels limits the list to cards with any or all of its labels.
+	Labels LabelMatch
+}
+
 type CardRepository interface {
+	// Create returns ErrColumnNotFound if the column of the card does not
+	// belong to its user; Update and MoveToColumn treat that like a missing
+	// card.
 	Create(ctx context.Context, Card *models.Card) error
 	GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Card, error)
+	// GetAll returns every card of a user, including those on archived
+	// boards.
 	GetAll(ctx context.Context, id uuid.UUID) (*[]models.Card, error)
-	GetPending(ctx context.Context, id uuid.UUID) (*[]models.Card, error)
+	List(ctx context.Context, userID uuid.UUID, filter CardFilter) (*[]models.Card, error)
 	Update(ctx context.Context, Card *models.Card, userID uuid.UUID) error
-	UpdateStatus(ctx context.Context, cardID uuid.UUID, status int8, userID uuid.UUID) error
+	// MoveToColumn moves a card to the end of a column, which may be on
+	// another board.
+	MoveToColumn(ctx context.Context, cardID uuid.UUID, columnID uuid.UUID, userID uuid.UUID) error
+	// Move puts a card into a column between the cards previousID and
+	// nextID, either of which may be nil. Only the moved card is written
+	// unless its keys have grown too long and the column is rebalanced.
+	Move(ctx context.Context, cardID uuid.UUID, columnID uuid.UUID, userID uuid.UUID, previousID, nextID *uuid.UUID) error
 	//have to be used
 	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	// Import inserts a card with its own ID and timestamps. It returns false
+	// if a card with the ID already exists.
+	Import(ctx context.Context, card *models.Card) (bool, error)
+	DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error)
 }
 
 type cardRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewCardRepository(db *sql.DB) CardRepository {
+func NewCardRepository(db DBTX) CardRepository {
 	return &cardRepository{db: db}
 }
 
-func (r *cardRepository) Create(ctx context.Context, card *models.Card) error {
-	query := `
-		INSERT INTO cards (title, description, status, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		RETURNING id, created_at, updated_at`
-	err := r.db.QueryRowContext(ctx, query, card.Title, card.Description, card.Status, card.UserID).Scan(&card.ID, &card.CreatedAt, &card.UpdatedAt)
-	if err != nil {
-		return fmt.Errorf("error creating card: %v", err)
-	}
-	return nil
-}
+// cardColumns is the column list read by scanCard.
+var cardColumns = `cards.id, cards.title, cards.description, cards.board_id, cards.column_id, cards.position, cards.starts_at, cards.due_at, cards.user_id, cards.created_at, cards.updated_at, ` + labelIDsColumn(LabelCards)
 
-func (r *cardRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Card, error) {
+func scanCard(row rowScanner) (*models.Card, error) {
 	card := models.Card{}
-	query := `SELECT id, title, description, status, user_id, created_at, updated_at FROM cards WHERE id = $1 AND user_id = $2`
-	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&card.ID, &card.Title, &card.Description, &card.Status, &card.UserID, &card.CreatedAt, &card.UpdatedAt)
-	if err == sql.ErrNoRows {
-		return nil, errors.New("card not found")
-	}
+	err := row.Scan(
+		&card.ID,
+		&card.Title,
+		&card.Description,
+		&card.BoardID,
+		&card.ColumnID,
+		&card.Position,
+		&card.StartsAt,
+		&card.DueAt,
+		&card.UserID,
+		&card.CreatedAt,
+		&card.UpdatedAt,
+		labelIDs{&card.LabelIDs},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("error getting card: %v", err)
+		return nil, err
 	}
 	return &card, nil
 }
-func (r *cardRepository) GetAll(ctx context.Context, id uuid.UUID) (*[]models.Card, error) {
-	query := `SELECT id, title, description, status, user_id, created_at,updated_at FROM cards where user_id = $1`
-	result, err := r.db.QueryContext(ctx, query, id)
+
+func (r *cardRepository) queryCards(ctx context.Context, query string, args ...any) (*[]models.Card, error) {
+	result, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying cards: %v", err)
 	}
 	defer result.Close()
 	var cards []models.Card
 	for result.Next() {
-		var card models.Card
-		err := result.Scan(
-			&card.ID,
-			&card.Title,
-			&card.Description,
-			&card.Status,
-			&card.UserID,
-			&card.CreatedAt,
-			&card.UpdatedAt,
-		)
+		card, err := scanCard(result)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning card: %v", err)
 		}
-		cards = append(cards, card)
+		cards = append(cards, *card)
 	}
 	if err = result.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %v", err)
+		return nil, fmt.Errorf("error iterating cards: %v", err)
 	}
 	return &cards, nil
 }
 
-func (r *cardRepository) GetPending(ctx context.Context, id uuid.UUID) (*[]models.Card, error) {
-	query := `SELECT id, title, description, status, user_id, created_at,updated_at FROM cards where user_id = $1 AND status=1`
-	result, err := r.db.QueryContext(ctx, query, id)
+// Create adds the card after the last one of its column.
+func (r *cardRepository) Create(ctx context.Context, card *models.Card) error {
+	position, err := r.position(ctx, uuid.Nil, card.ColumnID, card.UserID, nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error querying cards: %v", err)
+		return err
 	}
-	defer result.Close()
-	var cards []models.Card
-	for result.Next() {
-		var card models.Card
-		err := result.Scan(
-			&card.ID,
-			&card.Title,
-			&card.Description,
-			&card.Status,
-			&card.UserID,
-			&card.CreatedAt,
-			&card.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning card: %v", err)
-		}
-		cards = append(cards, card)
+	query := `
+		INSERT INTO cards (title, description, board_id, column_id, position, starts_at, due_at, user_id, created_at, updated_at)
+		SELECT $1, $2, board_id, id, $5, $6, $7, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM board_columns WHERE id = $3 AND user_id = $4
+		RETURNING id, board_id, position, created_at, updated_at`
+	err = r.db.QueryRowContext(ctx, query, card.Title, card.Description, card.ColumnID, card.UserID, position, card.StartsAt, card.DueAt).
+		Scan(&card.ID, &card.BoardID, &card.Position, &card.CreatedAt, &card.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrColumnNotFound
 	}
-	if err = result.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %v", err)
+	if err != nil {
+		return fmt.Errorf("error creating card: %v", err)
 	}
-	return &cards, nil
+	return nil
 }
 
+func (r *cardRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE id = $1 AND user_id = $2`
+	card, err := scanCard(r.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("card not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting card: %v", err)
+	}
+	return card, nil
+}
 
+func (r *cardRepository) GetAll(ctx context.Context, id uuid.UUID) (*[]models.Card, error) {
+	query := `SELECT ` + cardColumns + ` FROM cards where user_id = $1 ORDER BY cards.column_id, cards.position, cards.id`
+	return r.queryCards(ctx, query, id)
+}
+
+func (r *cardRepository) List(ctx context.Context, userID uuid.UUID, filter CardFilter) (*[]models.Card, error) {
+	query := `
+		SELECT ` + cardColumns + ` FROM cards
+		JOIN boards ON boards.id = cards.board_id
+		JOIN board_columns ON board_columns.id = cards.column_id
+		WHERE cards.user_id = $1`
+	args := []any{userID}
+	if filter.BoardID != nil {
+		args = append(args, *filter.BoardID)
+		query += fmt.Sprintf(" AND cards.board_id = $%d", len(args))
+	} else {
+		query += " AND boards.archived_at IS NULL"
+	}
+	if filter.Pending {
+		query += " AND NOT board_columns.is_done"
+	}
+	if filter.DueFrom != nil {
+		args = append(args, *filter.DueFrom)
+		query += fmt.Sprintf(" AND cards.due_at >= $%d", len(args))
+	}
+	if filter.DueBefore != nil {
+		args = append(args, *filter.DueBefore)
+		query += fmt.Sprintf(" AND cards.due_at < $%d", len(args))
+	}
+	if len(filter.Labels.LabelIDs) > 0 {
+		query += filter.Labels.condition(LabelCards, &args)
+	}
+	if filter.DueFrom != nil || filter.DueBefore != nil {
+		query += " ORDER BY cards.due_at, cards.id"
+	} else {
+		query += " ORDER BY boards.created_at, board_columns.position, cards.position, cards.id"
+	}
+	return r.queryCards(ctx, query, args...)
+}
+
+// Update keeps the position of a card that stays in its column and puts it
+// at the end of a new column.
 func (r *cardRepository) Update(ctx context.Context, card *models.Card, userID uuid.UUID) error {
+	position, err := r.position(ctx, card.ID, card.ColumnID, userID, nil, nil)
+	if err != nil {
+		return err
+	}
 	query := `
 		UPDATE cards
-		SET title = $1, description = $2, status = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4 AND user_id = $5
-		RETURNING updated_at`
-	result, err := r.db.ExecContext(ctx, query, card.Title, card.Description, card.Status, card.ID, userID)
+		SET title = $1, description = $2, board_id = board_columns.board_id, column_id = board_columns.id,
+			position = CASE WHEN cards.column_id = board_columns.id THEN cards.position ELSE $6 END,
+			starts_at = $7, due_at = $8, updated_at = CURRENT_TIMESTAMP
+		FROM board_columns
+		WHERE cards.id = $4 AND cards.user_id = $5 AND board_columns.id = $3 AND board_columns.user_id = $5`
+	return r.updateOne(ctx, "error updating card", query, card.Title, card.Description, card.ColumnID, card.ID, userID, position, card.StartsAt, card.DueAt)
+}
+
+func (r *cardRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM cards WHERE id = $1 and user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
 	if err != nil {
-		return fmt.Errorf("error updating user: %v", err)
+		return fmt.Errorf("error deleting card: %v", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -131,46 +213,185 @@ func (r *cardRepository) Update(ctx context.Context, card *models.Card, userID u
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("user not found")
+		return errors.New("card not found")
 	}
-
 	return nil
 }
 
-func (r *cardRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
-	query := `DELETE FROM cards WHERE id = $1 and user_id = $2`
-	result, err := r.db.ExecContext(ctx, query, id, userID)
+func (r *cardRepository) MoveToColumn(ctx context.Context, id uuid.UUID, columnID uuid.UUID, userID uuid.UUID) error {
+	position, err := r.position(ctx, id, columnID, userID, nil, nil)
 	if err != nil {
-		return fmt.Errorf("error deleting card: %v", err)
+		return err
+	}
+	query := `
+		UPDATE cards
+		SET board_id = board_columns.board_id, column_id = board_columns.id,
+			position = CASE WHEN cards.column_id = board_columns.id THEN cards.position ELSE $4 END,
+			updated_at = CURRENT_TIMESTAMP
+		FROM board_columns
+		WHERE cards.id = $2 AND cards.user_id = $3 AND board_columns.id = $1 AND board_columns.user_id = $3`
+	return r.updateOne(ctx, "error moving card", query, columnID, id, userID, position)
+}
+
+func (r *cardRepository) Move(ctx context.Context, id uuid.UUID, columnID uuid.UUID, userID uuid.UUID, previousID, nextID *uuid.UUID) error {
+	if (previousID != nil && *previousID == id) || (nextID != nil && *nextID == id) {
+		return ErrInvalidNeighbors
+	}
+	position, err := r.position(ctx, id, columnID, userID, previousID, nextID)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE cards
+		SET board_id = board_columns.board_id, column_id = board_columns.id, position = $4, updated_at = CURRENT_TIMESTAMP
+		FROM board_columns
+		WHERE cards.id = $2 AND cards.user_id = $3 AND board_columns.id = $1 AND board_columns.user_id = $3`
+	return r.updateOne(ctx, "error moving card", query, columnID, id, userID, position)
+}
+
+// position returns a key for the card id in a column between the cards
+// previousID and nextID. Without previousID the card goes after the card
+// before nextID, and without either after the last card of the column.
+// If there is no short enough key the column is rebalanced first.
+func (r *cardRepository) position(ctx context.Context, id uuid.UUID, columnID uuid.UUID, userID uuid.UUID, previousID, nextID *uuid.UUID) (string, error) {
+	lower, upper, err := r.neighbors(ctx, id, columnID, userID, previousID, nextID)
+	if err != nil {
+		return "", err
+	}
+	position, err := rank.Between(lower, upper)
+	if err == nil && len(position) <= rank.MaxLength {
+		return position, nil
+	}
+	if err := r.rebalance(ctx, columnID, userID); err != nil {
+		return "", err
+	}
+	if lower, upper, err = r.neighbors(ctx, id, columnID, userID, previousID, nextID); err != nil {
+		return "", err
+	}
+	position, err = rank.Between(lower, upper)
+	if err != nil {
+		return "", ErrInvalidNeighbors
+	}
+	return position, nil
+}
+
+// neighbors returns the positions the card id is placed between, leaving
+// out the card itself. An empty position is the start or end of the column.
+func (r *cardRepository) neighbors(ctx context.Context, id uuid.UUID, columnID uuid.UUID, userID uuid.UUID, previousID, nextID *uuid.UUID) (string, string, error) {
+	var lower, upper string
+	var err error
+	if previousID != nil {
+		if lower, err = r.neighborPosition(ctx, *previousID, columnID, userID); err != nil {
+			return "", "", err
+		}
+	}
+	if nextID != nil {
+		if upper, err = r.neighborPosition(ctx, *nextID, columnID, userID); err != nil {
+			return "", "", err
+		}
+	}
+	switch {
+	case previousID != nil && nextID == nil:
+		query := `SELECT COALESCE(MIN(position), '') FROM cards WHERE column_id = $1 AND user_id = $2 AND id <> $3 AND position > $4`
+		err = r.db.QueryRowContext(ctx, query, columnID, userID, id, lower).Scan(&upper)
+	case previousID == nil:
+		query := `SELECT COALESCE(MAX(position), '') FROM cards WHERE column_id = $1 AND user_id = $2 AND id <> $3 AND ($4 = '' OR position < $4)`
+		err = r.db.QueryRowContext(ctx, query, columnID, userID, id, upper).Scan(&lower)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("error getting card positions: %v", err)
+	}
+	return lower, upper, nil
+}
+
+func (r *cardRepository) neighborPosition(ctx context.Context, id uuid.UUID, columnID uuid.UUID, userID uuid.UUID) (string, error) {
+	var position string
+	err := r.db.QueryRowContext(ctx, `SELECT position FROM cards WHERE id = $1 AND column_id = $2 AND user_id = $3`, id, columnID, userID).Scan(&position)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidNeighbors
 	}
+	if err != nil {
+		return "", fmt.Errorf("error getting card position: %v", err)
+	}
+	return position, nil
+}
 
+// rebalance gives the cards of a column evenly spaced short positions in
+// their current order.
+func (r *cardRepository) rebalance(ctx context.Context, columnID uuid.UUID, userID uuid.UUID) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM cards WHERE column_id = $1 AND user_id = $2 ORDER BY position, id`, columnID, userID)
+	if err != nil {
+		return fmt.Errorf("error querying card positions: %v", err)
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("error scanning card position: %v", err)
+		}
+		ids = append(ids, id.String())
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating card positions: %v", err)
+	}
+	query := `
+		UPDATE cards SET position = p.position
+		FROM unnest($1::uuid[], $2::text[]) AS p (id, position)
+		WHERE cards.id = p.id`
+	if _, err := r.db.ExecContext(ctx, query, ids, rank.Spread(len(ids))); err != nil {
+		return fmt.Errorf("error rebalancing cards: %v", err)
+	}
+	return nil
+}
+
+// updateOne runs an update of a single card and reports a missing card, or
+// a column of someone else, as "card not found".
+func (r *cardRepository) updateOne(ctx context.Context, msg string, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %v", msg, err)
+	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("error getting rows affected: %v", err)
 	}
-
 	if rowsAffected == 0 {
 		return errors.New("card not found")
 	}
 	return nil
 }
 
-func (r *cardRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status int8, userID uuid.UUID) error {
-
+// Import adds the card after the last one of its column.
+func (r *cardRepository) Import(ctx context.Context, card *models.Card) (bool, error) {
+	position, err := r.position(ctx, card.ID, card.ColumnID, card.UserID, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	card.Position = position
 	query := `
-		UPDATE cards
-		SET status = $1, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $2 AND user_id = $3
-		RETURNING status,updated_at`
-	result, err := r.db.ExecContext(ctx, query, status, id, userID)
+		INSERT INTO cards (id, title, description, board_id, column_id, position, starts_at, due_at, user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, card.ID, card.Title, card.Description, card.BoardID, card.ColumnID, card.Position, card.StartsAt, card.DueAt, card.UserID, card.CreatedAt, card.UpdatedAt)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("error importing card: %v", err)
 	}
-	num, err := result.RowsAffected()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		fmt.Println("Error getting rows affected:", err)
-		return err
+		return false, fmt.Errorf("error getting rows affected: %v", err)
 	}
-	fmt.Println("Rows affected:", num)
-	return nil
+	return rowsAffected == 1, nil
+}
+
+func (r *cardRepository) DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM cards WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting cards: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected, nil
 }
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReminderNotFound = errors.New("reminder not found")
+	// ErrReminderExists is returned when a card already has a reminder the
+	// same time before its due date.
+	ErrReminderExists = errors.New("reminder already exists")
+)
+
+type CardReminderRepository interface {
+	// GetForCard returns the reminders of a card, earliest first.
+	GetForCard(ctx context.Context, cardID uuid.UUID, userID uuid.UUID) (*[]models.CardReminder, error)
+	// GetAll returns the reminders of every card of the user.
+	GetAll(ctx context.Context, userID uuid.UUID) (*[]models.CardReminder, error)
+	// Create adds a reminder to a card of the user. It returns
+	// ErrReminderExists if the card has one with the same MinutesBefore and
+	// ErrReminderNotFound if the card is not the user's.
+	Create(ctx context.Context, reminder *models.CardReminder) error
+	Delete(ctx context.Context, id uuid.UUID, cardID uuid.UUID, userID uuid.UUID) error
+	// Due returns the reminders that should have fired after since and up
+	// to now and did not fire for the current due date of their card yet.
+	// Cards in done columns or on archived boards and users who are
+	// disabled or being deleted are left out.
+	Due(ctx context.Context, since time.Time, now time.Time, limit int) (*[]models.DueReminder, error)
+	// MarkSent records that a reminder fired for dueAt. It returns false if
+	// it already had, so that only one process sends it.
+	MarkSent(ctx context.Context, id uuid.UUID, dueAt time.Time) (bool, error)
+}
+
+type cardReminderRepository struct {
+	db DBTX
+}
+
+func NewCardReminderRepository(db DBTX) CardReminderRepository {
+	return &cardReminderRepository{db: db}
+}
+
+const cardReminderColumns = `card_reminders.id, card_reminders.card_id, card_reminders.user_id, card_reminders.minutes_before, card_reminders.sent_at, card_reminders.created_at`
+
+func (r *cardReminderRepository) GetForCard(ctx context.Context, cardID uuid.UUID, userID uuid.UUID) (*[]models.CardReminder, error) {
+	query := `SELECT ` + cardReminderColumns + ` FROM card_reminders WHERE card_id = $1 AND user_id = $2 ORDER BY minutes_before DESC`
+	return r.queryReminders(ctx, query, cardID, userID)
+}
+
+func (r *cardReminderRepository) GetAll(ctx context.Context, userID uuid.UUID) (*[]models.CardReminder, error) {
+	query := `SELECT ` + cardReminderColumns + ` FROM card_reminders WHERE user_id = $1 ORDER BY card_id, minutes_before DESC`
+	return r.queryReminders(ctx, query, userID)
+}
+
+func (r *cardReminderRepository) queryReminders(ctx context.Context, query string, args ...any) (*[]models.CardReminder, error) {
+	result, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reminders: %v", err)
+	}
+	defer result.Close()
+	reminders := []models.CardReminder{}
+	for result.Next() {
+		var reminder models.CardReminder
+		if err := result.Scan(&reminder.ID, &reminder.CardID, &reminder.UserID, &reminder.MinutesBefore, &reminder.SentAt, &reminder.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning reminder: %v", err)
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reminders: %v", err)
+	}
+	return &reminders, nil
+}
+
+func (r *cardReminderRepository) Create(ctx context.Context, reminder *models.CardReminder) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM cards WHERE id = $1 AND user_id = $2)`, reminder.CardID, reminder.UserID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error getting card: %v", err)
+	}
+	if !exists {
+		return ErrReminderNotFound
+	}
+	query := `
+		INSERT INTO card_reminders (card_id, user_id, minutes_before)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (card_id, minutes_before) DO NOTHING
+		RETURNING id, created_at`
+	err = r.db.QueryRowContext(ctx, query, reminder.CardID, reminder.UserID, reminder.MinutesBefore).Scan(&reminder.ID, &reminder.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrReminderExists
+	}
+	if err != nil {
+		return fmt.Errorf("error creating reminder: %v", err)
+	}
+	return nil
+}
+
+func (r *cardReminderRepository) Delete(ctx context.Context, id uuid.UUID, cardID uuid.UUID, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM card_reminders WHERE id = $1 AND card_id = $2 AND user_id = $3`, id, cardID, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting reminder: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+func (r *cardReminderRepository) Due(ctx context.Context, since time.Time, now time.Time, limit int) (*[]models.DueReminder, error) {
+	query := `
+		SELECT ` + cardReminderColumns + `, cards.title, cards.due_at, users.email, users.first_name
+		FROM card_reminders
+		JOIN cards ON cards.id = card_reminders.card_id
+		JOIN board_columns ON board_columns.id = cards.column_id
+		JOIN boards ON boards.id = cards.board_id
+		JOIN users ON users.id = card_reminders.user_id
+		WHERE cards.due_at IS NOT NULL
+			AND card_reminders.sent_for IS DISTINCT FROM cards.due_at
+			AND cards.due_at - card_reminders.minutes_before * INTERVAL '1 minute' > $1
+			AND cards.due_at - card_reminders.minutes_before * INTERVAL '1 minute' <= $2
+			AND NOT board_columns.is_done
+			AND boards.archived_at IS NULL
+			AND users.disabled_at IS NULL
+			AND users.deletion_scheduled_at IS NULL
+		ORDER BY cards.due_at - card_reminders.minutes_before * INTERVAL '1 minute'
+		LIMIT $3`
+	result, err := r.db.QueryContext(ctx, query, since, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying due reminders: %v", err)
+	}
+	defer result.Close()
+	reminders := []models.DueReminder{}
+	for result.Next() {
+		var reminder models.DueReminder
+		err := result.Scan(
+			&reminder.ID,
+			&reminder.CardID,
+			&reminder.UserID,
+			&reminder.MinutesBefore,
+			&reminder.SentAt,
+			&reminder.CreatedAt,
+			&reminder.CardTitle,
+			&reminder.DueAt,
+			&reminder.Email,
+			&reminder.FirstName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning due reminder: %v", err)
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating due reminders: %v", err)
+	}
+	return &reminders, nil
+}
+
+func (r *cardReminderRepository) MarkSent(ctx context.Context, id uuid.UUID, dueAt time.Time) (bool, error) {
+	query := `
+		UPDATE card_reminders SET sent_for = $2, sent_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND sent_for IS DISTINCT FROM $2`
+	result, err := r.db.ExecContext(ctx, query, id, dueAt)
+	if err != nil {
+		return false, fmt.Errorf("error marking reminder as sent: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
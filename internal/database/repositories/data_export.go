@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrDataExportNotFound = errors.New("data export not found")
+
+type DataExportRepository interface {
+	// Create starts a pending export. It returns false if the user already
+	// has one pending that was started within the last hour; older ones are
+	// assumed to have died with their server.
+	Create(ctx context.Context, export *models.DataExport) (bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error)
+	GetAll(ctx context.Context, userID uuid.UUID) (*[]models.DataExport, error)
+	MarkReady(ctx context.Context, id uuid.UUID, size int64, expiresAt time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string) error
+	// Prune deletes exports that expired before now, and failed or abandoned
+	// ones after a day.
+	Prune(ctx context.Context, now time.Time) (int64, error)
+}
+
+type dataExportRepository struct {
+	db *sql.DB
+}
+
+func NewDataExportRepository(db *sql.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+func (r *dataExportRepository) Create(ctx context.Context, export *models.DataExport) (bool, error) {
+	query := `
+		INSERT INTO data_exports (user_id, status, created_at)
+		SELECT $1, $2, CURRENT_TIMESTAMP
+		WHERE NOT EXISTS (
+			SELECT 1 FROM data_exports
+			WHERE user_id = $1 AND status = $2 AND created_at > CURRENT_TIMESTAMP - INTERVAL '1 hour'
+		)
+		RETURNING id, status, created_at`
+	err := r.db.QueryRowContext(ctx, query, export.UserID, models.ExportPending).Scan(&export.ID, &export.Status, &export.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error creating data export: %v", err)
+	}
+	return true, nil
+}
+
+const dataExportColumns = `id, user_id, status, size_bytes, error, expires_at, completed_at, created_at`
+
+func scanDataExport(row rowScanner) (*models.DataExport, error) {
+	export := models.DataExport{}
+	err := row.Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.SizeBytes,
+		&export.Error,
+		&export.ExpiresAt,
+		&export.CompletedAt,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *dataExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1`
+	export, err := scanDataExport(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrDataExportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting data export: %v", err)
+	}
+	return export, nil
+}
+
+func (r *dataExportRepository) GetAll(ctx context.Context, userID uuid.UUID) (*[]models.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE user_id = $1 ORDER BY created_at DESC`
+	result, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying data exports: %v", err)
+	}
+	defer result.Close()
+	exports := []models.DataExport{}
+	for result.Next() {
+		export, err := scanDataExport(result)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning data export: %v", err)
+		}
+		exports = append(exports, *export)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating data exports: %v", err)
+	}
+	return &exports, nil
+}
+
+func (r *dataExportRepository) MarkReady(ctx context.Context, id uuid.UUID, size int64, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports SET status = $1, size_bytes = $2, expires_at = $3, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $4`
+	if _, err := r.db.ExecContext(ctx, query, models.ExportReady, size, expiresAt, id); err != nil {
+		return fmt.Errorf("error updating data export: %v", err)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `UPDATE data_exports SET status = $1, error = $2, completed_at = CURRENT_TIMESTAMP WHERE id = $3`
+	if _, err := r.db.ExecContext(ctx, query, models.ExportFailed, reason, id); err != nil {
+		return fmt.Errorf("error updating data export: %v", err)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) Prune(ctx context.Context, now time.Time) (int64, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE expires_at < $1 OR (status <> $2 AND created_at < $1::timestamptz - INTERVAL '1 day')`
+	result, err := r.db.ExecContext(ctx, query, now, models.ExportReady)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning data exports: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so repositories built on
+// it can take part in a transaction started by the caller.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
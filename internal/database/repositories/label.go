@@ -0,0 +1,321 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrLabelNotFound = errors.New("label not found")
+	// ErrLabelExists is returned when a user already has a label with the
+	// same name, ignoring case.
+	ErrLabelExists = errors.New("label already exists")
+)
+
+// LabelTarget is the kind of item a label is put on.
+type LabelTarget string
+
+const (
+	LabelCards LabelTarget = "cards"
+	LabelNotes LabelTarget = "notes"
+)
+
+// labelLinks names the link table of a target and its item column.
+var labelLinks = map[LabelTarget][2]string{
+	LabelCards: {"card_labels", "card_id"},
+	LabelNotes: {"note_labels", "note_id"},
+}
+
+// LabelMatch narrows a list of cards or notes to those with any or all of
+// LabelIDs. An empty LabelIDs matches everything.
+type LabelMatch struct {
+	LabelIDs []uuid.UUID
+	All      bool
+}
+
+// condition returns a SQL condition on the items of target for the match,
+// appending its arguments to args.
+func (m LabelMatch) condition(target LabelTarget, args *[]any) string {
+	link := labelLinks[target]
+	ids := make([]string, 0, len(m.LabelIDs))
+	seen := map[uuid.UUID]bool{}
+	for _, id := range m.LabelIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id.String())
+		}
+	}
+	*args = append(*args, ids)
+	subquery := fmt.Sprintf(`SELECT 1 FROM %s WHERE %s = %s.id AND label_id = ANY($%d::uuid[])`, link[0], link[1], target, len(*args))
+	if !m.All {
+		return ` AND EXISTS (` + subquery + `)`
+	}
+	*args = append(*args, len(ids))
+	return fmt.Sprintf(` AND (SELECT COUNT(*) FROM (%s) AS matched) = $%d`, subquery, len(*args))
+}
+
+// labelIDsColumn selects the comma separated label IDs of the items of
+// target, to be read with parseLabelIDs.
+func labelIDsColumn(target LabelTarget) string {
+	link := labelLinks[target]
+	return fmt.Sprintf(`(SELECT COALESCE(string_agg(label_id::text, ',' ORDER BY label_id), '') FROM %s WHERE %s = %s.id)`, link[0], link[1], target)
+}
+
+func parseLabelIDs(list string) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	if list == "" {
+		return ids, nil
+	}
+	for _, raw := range strings.Split(list, ",") {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// labelIDs scans the column selected by labelIDsColumn.
+type labelIDs struct {
+	ids *[]uuid.UUID
+}
+
+func (l labelIDs) Scan(src any) error {
+	var list string
+	switch v := src.(type) {
+	case string:
+		list = v
+	case []byte:
+		list = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into label IDs", src)
+	}
+	ids, err := parseLabelIDs(list)
+	if err != nil {
+		return err
+	}
+	*l.ids = ids
+	return nil
+}
+
+type LabelRepository interface {
+	// GetAll returns the labels of a user by name with the number of cards
+	// and notes they are on.
+	GetAll(ctx context.Context, userID uuid.UUID) (*[]models.LabelCount, error)
+	GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Label, error)
+	Create(ctx context.Context, label *models.Label) error
+	Update(ctx context.Context, label *models.Label) error
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	// Assign puts a label on an item of the target. Both must belong to
+	// the user, else ErrLabelNotFound is returned.
+	Assign(ctx context.Context, target LabelTarget, itemID uuid.UUID, labelID uuid.UUID, userID uuid.UUID) error
+	// Unassign takes a label off an item. Like Assign it does nothing if
+	// the label is not there, and returns ErrLabelNotFound only if the
+	// label is not the user's.
+	Unassign(ctx context.Context, target LabelTarget, itemID uuid.UUID, labelID uuid.UUID, userID uuid.UUID) error
+	// Set replaces the labels of an item of the target, which must belong
+	// to the user. It returns ErrLabelNotFound if any label is not the
+	// user's.
+	Set(ctx context.Context, target LabelTarget, itemID uuid.UUID, labelIDs []uuid.UUID, userID uuid.UUID) error
+	DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type labelRepository struct {
+	db DBTX
+}
+
+func NewLabelRepository(db DBTX) LabelRepository {
+	return &labelRepository{db: db}
+}
+
+const labelColumns = `labels.id, labels.user_id, labels.name, labels.color, labels.created_at, labels.updated_at`
+
+func (r *labelRepository) GetAll(ctx context.Context, userID uuid.UUID) (*[]models.LabelCount, error) {
+	query := `
+		SELECT ` + labelColumns + `,
+			(SELECT COUNT(*) FROM card_labels
+				JOIN cards ON cards.id = card_labels.card_id
+				JOIN boards ON boards.id = cards.board_id
+				WHERE card_labels.label_id = labels.id AND boards.archived_at IS NULL),
+			(SELECT COUNT(*) FROM note_labels WHERE note_labels.label_id = labels.id)
+		FROM labels
+		WHERE labels.user_id = $1
+		ORDER BY LOWER(labels.name)`
+	result, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying labels: %v", err)
+	}
+	defer result.Close()
+	labels := []models.LabelCount{}
+	for result.Next() {
+		var label models.LabelCount
+		err := result.Scan(&label.ID, &label.UserID, &label.Name, &label.Color, &label.CreatedAt, &label.UpdatedAt, &label.CardCount, &label.NoteCount)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning label: %v", err)
+		}
+		labels = append(labels, label)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating labels: %v", err)
+	}
+	return &labels, nil
+}
+
+func (r *labelRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Label, error) {
+	var label models.Label
+	query := `SELECT ` + labelColumns + ` FROM labels WHERE id = $1 AND user_id = $2`
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&label.ID, &label.UserID, &label.Name, &label.Color, &label.CreatedAt, &label.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrLabelNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting label: %v", err)
+	}
+	return &label, nil
+}
+
+func (r *labelRepository) Create(ctx context.Context, label *models.Label) error {
+	query := `
+		INSERT INTO labels (user_id, name, color)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, label.UserID, label.Name, label.Color).Scan(&label.ID, &label.CreatedAt, &label.UpdatedAt)
+	if err != nil {
+		return labelError("error creating label", err)
+	}
+	return nil
+}
+
+func (r *labelRepository) Update(ctx context.Context, label *models.Label) error {
+	query := `
+		UPDATE labels SET name = $1, color = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND user_id = $4
+		RETURNING updated_at`
+	err := r.db.QueryRowContext(ctx, query, label.Name, label.Color, label.ID, label.UserID).Scan(&label.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrLabelNotFound
+	}
+	if err != nil {
+		return labelError("error updating label", err)
+	}
+	return nil
+}
+
+// labelError reports a duplicate name as ErrLabelExists.
+func labelError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrLabelExists
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
+
+func (r *labelRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM labels WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting label: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrLabelNotFound
+	}
+	return nil
+}
+
+func (r *labelRepository) Assign(ctx context.Context, target LabelTarget, itemID uuid.UUID, labelID uuid.UUID, userID uuid.UUID) error {
+	link := labelLinks[target]
+	query := fmt.Sprintf(`
+		WITH assigned AS (
+			INSERT INTO %[1]s (%[2]s, label_id)
+			SELECT %[3]s.id, labels.id FROM %[3]s, labels
+			WHERE %[3]s.id = $1 AND %[3]s.user_id = $3 AND labels.id = $2 AND labels.user_id = $3
+			ON CONFLICT DO NOTHING
+		)
+		SELECT EXISTS (SELECT 1 FROM %[3]s WHERE id = $1 AND user_id = $3)
+			AND EXISTS (SELECT 1 FROM labels WHERE id = $2 AND user_id = $3)`, link[0], link[1], target)
+	var found bool
+	if err := r.db.QueryRowContext(ctx, query, itemID, labelID, userID).Scan(&found); err != nil {
+		return fmt.Errorf("error assigning label: %v", err)
+	}
+	if !found {
+		return ErrLabelNotFound
+	}
+	return nil
+}
+
+func (r *labelRepository) Unassign(ctx context.Context, target LabelTarget, itemID uuid.UUID, labelID uuid.UUID, userID uuid.UUID) error {
+	link := labelLinks[target]
+	query := fmt.Sprintf(`
+		WITH removed AS (
+			DELETE FROM %[1]s
+			WHERE %[2]s = $1 AND label_id = $2
+				AND EXISTS (SELECT 1 FROM labels WHERE id = $2 AND user_id = $3)
+		)
+		SELECT EXISTS (SELECT 1 FROM labels WHERE id = $2 AND user_id = $3)`, link[0], link[1])
+	var found bool
+	if err := r.db.QueryRowContext(ctx, query, itemID, labelID, userID).Scan(&found); err != nil {
+		return fmt.Errorf("error removing label: %v", err)
+	}
+	if !found {
+		return ErrLabelNotFound
+	}
+	return nil
+}
+
+func (r *labelRepository) Set(ctx context.Context, target LabelTarget, itemID uuid.UUID, labelIDs []uuid.UUID, userID uuid.UUID) error {
+	ids := make([]string, 0, len(labelIDs))
+	for _, id := range labelIDs {
+		ids = append(ids, id.String())
+	}
+	var owned int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM labels WHERE id = ANY($1::uuid[]) AND user_id = $2`, ids, userID).Scan(&owned)
+	if err != nil {
+		return fmt.Errorf("error getting labels: %v", err)
+	}
+	distinct := map[uuid.UUID]bool{}
+	for _, id := range labelIDs {
+		distinct[id] = true
+	}
+	if owned != len(distinct) {
+		return ErrLabelNotFound
+	}
+	link := labelLinks[target]
+	query := fmt.Sprintf(`
+		WITH item AS (
+			SELECT id FROM %[3]s WHERE id = $1 AND user_id = $3
+		), removed AS (
+			DELETE FROM %[1]s WHERE %[2]s IN (SELECT id FROM item) AND label_id <> ALL($2::uuid[])
+		)
+		INSERT INTO %[1]s (%[2]s, label_id)
+		SELECT item.id, labels.id FROM item, labels
+		WHERE labels.id = ANY($2::uuid[]) AND labels.user_id = $3
+		ON CONFLICT DO NOTHING`, link[0], link[1], target)
+	if _, err := r.db.ExecContext(ctx, query, itemID, ids, userID); err != nil {
+		return fmt.Errorf("error setting labels: %v", err)
+	}
+	return nil
+}
+
+func (r *labelRepository) DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM labels WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting labels: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLabelMatchCondition(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	tests := []struct {
+		name      string
+		target    LabelTarget
+		match     LabelMatch
+		condition string
+		args      []any
+	}{
+		{
+			name:      "any card label",
+			target:    LabelCards,
+			match:     LabelMatch{LabelIDs: []uuid.UUID{a, b}},
+			condition: ` AND EXISTS (SELECT 1 FROM card_labels WHERE card_id = cards.id AND label_id = ANY($2::uuid[]))`,
+			args:      []any{"user", []string{a.String(), b.String()}},
+		},
+		{
+			name:      "all note labels",
+			target:    LabelNotes,
+			match:     LabelMatch{LabelIDs: []uuid.UUID{a, b}, All: true},
+			condition: ` AND (SELECT COUNT(*) FROM (SELECT 1 FROM note_labels WHERE note_id = notes.id AND label_id = ANY($2::uuid[])) AS matched) = $3`,
+			args:      []any{"user", []string{a.String(), b.String()}, 2},
+		},
+		{
+			name:      "duplicates with any",
+			target:    LabelNotes,
+			match:     LabelMatch{LabelIDs: []uuid.UUID{a, a}},
+			condition: ` AND EXISTS (SELECT 1 FROM note_labels WHERE note_id = notes.id AND label_id = ANY($2::uuid[]))`,
+			args:      []any{"user", []string{a.String()}},
+		},
+		{
+			// Without deduplication the count could never reach 3.
+			name:      "duplicates with all",
+			target:    LabelCards,
+			match:     LabelMatch{LabelIDs: []uuid.UUID{a, b, a}, All: true},
+			condition: ` AND (SELECT COUNT(*) FROM (SELECT 1 FROM card_labels WHERE card_id = cards.id AND label_id = ANY($2::uuid[])) AS matched) = $3`,
+			args:      []any{"user", []string{a.String(), b.String()}, 2},
+		},
+	}
+	for _, tt := range tests {
+		args := []any{"user"}
+		condition := tt.match.condition(tt.target, &args)
+		if condition != tt.condition {
+			t.Errorf("%s: condition = %q, want %q", tt.name, condition, tt.condition)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.args)
+		}
+	}
+}
+
+func TestParseLabelIDs(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	ids, err := parseLabelIDs(a.String() + "," + b.String())
+	if err != nil {
+		t.Fatalf("parseLabelIDs returned error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []uuid.UUID{a, b}) {
+		t.Errorf("parseLabelIDs = %v, want %v", ids, []uuid.UUID{a, b})
+	}
+	ids, err = parseLabelIDs("")
+	if err != nil || ids == nil || len(ids) != 0 {
+		t.Errorf("expected an empty list for no labels, got %v, %v", ids, err)
+	}
+	if _, err := parseLabelIDs("not-a-uuid"); err == nil {
+		t.Error("expected parseLabelIDs to fail on an invalid ID")
+	}
+}
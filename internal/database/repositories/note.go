@@ -10,22 +10,39 @@ import (
 	"github.com/google/uuid"
 )
 
+// NoteFilter narrows the notes returned by List. A Limit of zero lists
+// every note.
+type NoteFilter struct {
+	Limit  int
+	Labels LabelMatch
+}
+
 type NoteRepository interface {
 	Create(ctx context.Context, Note *models.Note) error
 	GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Note, error)
 	GetAll(ctx context.Context, userID uuid.UUID, limit ...int) (*[]models.Note, error)
+	// List returns the notes of a user matching the filter, most recently
+	// updated first.
+	List(ctx context.Context, userID uuid.UUID, filter NoteFilter) (*[]models.Note, error)
 	Update(ctx context.Context, Note *models.Note, userID uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	// Import inserts a note with its own ID and timestamps. It returns false
+	// if a note with the ID already exists.
+	Import(ctx context.Context, note *models.Note) (bool, error)
+	DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error)
 }
 
 type noteRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewNoteRepository(db *sql.DB) NoteRepository {
+func NewNoteRepository(db DBTX) NoteRepository {
 	return &noteRepository{db: db}
 }
 
+// noteColumns is the column list read by List and GetByID.
+var noteColumns = `notes.id, notes.title, notes.content, notes.user_id, notes.created_at, notes.updated_at, ` + labelIDsColumn(LabelNotes)
+
 func (r *noteRepository) Create(ctx context.Context, note *models.Note) error {
 	query := `
 		INSERT INTO notes (title, content, user_id, created_at, updated_at)
@@ -41,8 +58,8 @@ func (r *noteRepository) Create(ctx context.Context, note *models.Note) error {
 func (r *noteRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Note, error) {
 	note := models.Note{}
 	
-	query := `SELECT id, title, content, user_id, created_at, updated_at FROM notes WHERE id = $1 AND user_id = $2`
-	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&note.ID, &note.Title, &note.Content, &note.UserID, &note.CreatedAt, &note.UpdatedAt)
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE id = $1 AND user_id = $2`
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&note.ID, &note.Title, &note.Content, &note.UserID, &note.CreatedAt, &note.UpdatedAt, labelIDs{&note.LabelIDs})
 	if err == sql.ErrNoRows {
 		return nil, errors.New("note not found")
 	}
@@ -53,15 +70,25 @@ func (r *noteRepository) GetByID(ctx context.Context, id uuid.UUID, userID uuid.
 }
 
 func (r *noteRepository) GetAll(ctx context.Context, userID uuid.UUID, limit ...int) (*[]models.Note, error) {
-	var result *sql.Rows
-	var err error
-	if len(limit) > 0 && limit[0] > 0 {
-		query := `SELECT id, title, content, user_id, created_at, updated_at FROM notes where user_id = $1 ORDER BY updated_at DESC LIMIT $2`
-		result, err = r.db.QueryContext(ctx, query, userID, limit[0])
-	} else {
-		query := `SELECT id, title, content, user_id, created_at, updated_at FROM notes WHERE user_id = $1 ORDER BY updated_at DESC`
-   		result, err = r.db.QueryContext(ctx, query, userID)
+	filter := NoteFilter{}
+	if len(limit) > 0 {
+		filter.Limit = limit[0]
+	}
+	return r.List(ctx, userID, filter)
+}
+
+func (r *noteRepository) List(ctx context.Context, userID uuid.UUID, filter NoteFilter) (*[]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE user_id = $1`
+	args := []any{userID}
+	if len(filter.Labels.LabelIDs) > 0 {
+		query += filter.Labels.condition(LabelNotes, &args)
 	}
+	query += " ORDER BY updated_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	result, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying notes: %v", err)
 	}
@@ -76,6 +103,7 @@ func (r *noteRepository) GetAll(ctx context.Context, userID uuid.UUID, limit ...
 			&note.UserID,
 			&note.CreatedAt,
 			&note.UpdatedAt,
+			labelIDs{&note.LabelIDs},
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning note: %v", err)
@@ -119,3 +147,31 @@ func (r *noteRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.U
 
 	return nil
 }
+
+func (r *noteRepository) Import(ctx context.Context, note *models.Note) (bool, error) {
+	query := `
+		INSERT INTO notes (id, title, content, user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, note.ID, note.Title, note.Content, note.UserID, note.CreatedAt, note.UpdatedAt)
+	if err != nil {
+		return false, fmt.Errorf("error importing note: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *noteRepository) DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM notes WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting notes: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	GetByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error)
+	GetAll(ctx context.Context, userID uuid.UUID) (*[]models.PersonalAccessToken, error)
+	TouchLastUsed(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type personalAccessTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db: db}
+}
+
+func (r *personalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, token.UserID, token.Name, token.TokenHash, token.Prefix, strings.Join(token.Scopes, " "), token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating personal access token: %v", err)
+	}
+	return nil
+}
+
+const personalAccessTokenColumns = `id, user_id, name, token_hash, token_prefix, scopes, last_used_at, expires_at, created_at`
+
+func scanPersonalAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	token := models.PersonalAccessToken{}
+	var scopes string
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Prefix,
+		&scopes,
+		&token.LastUsedAt,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	return &token, nil
+}
+
+func (r *personalAccessTokenRepository) GetByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + ` FROM personal_access_tokens WHERE token_hash = $1`
+	token, err := scanPersonalAccessToken(r.db.QueryRowContext(ctx, query, hash))
+	if err == sql.ErrNoRows {
+		return nil, ErrPersonalAccessTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting personal access token: %v", err)
+	}
+	return token, nil
+}
+
+func (r *personalAccessTokenRepository) GetAll(ctx context.Context, userID uuid.UUID) (*[]models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + ` FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+	result, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying personal access tokens: %v", err)
+	}
+	defer result.Close()
+	tokens := []models.PersonalAccessToken{}
+	for result.Next() {
+		token, err := scanPersonalAccessToken(result)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning personal access token: %v", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating personal access tokens: %v", err)
+	}
+	return &tokens, nil
+}
+
+func (r *personalAccessTokenRepository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE personal_access_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("error updating personal access token: %v", err)
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting personal access token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// RevokeAllForUser deletes every personal access token of the user.
+func (r *personalAccessTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM personal_access_tokens WHERE user_id = $1`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("error deleting personal access tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type RecoveryCodeRepository interface {
+	// Replace drops all recovery codes of the user and stores the new hashes.
+	Replace(ctx context.Context, userID uuid.UUID, hashes []string) error
+	// Consume marks an unused code as used and reports whether it existed.
+	Consume(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+	CountUnused(ctx context.Context, userID uuid.UUID) (int, error)
+	DeleteAll(ctx context.Context, userID uuid.UUID) error
+}
+
+type recoveryCodeRepository struct {
+	db *sql.DB
+}
+
+func NewRecoveryCodeRepository(db *sql.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+func (r *recoveryCodeRepository) Replace(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %v", err)
+	}
+	for _, hash := range hashes {
+		query := `INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, CURRENT_TIMESTAMP)`
+		if _, err := tx.ExecContext(ctx, query, userID, hash); err != nil {
+			return fmt.Errorf("error creating recovery code: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *recoveryCodeRepository) Consume(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %v", err)
+	}
+	return count, nil
+}
+
+func (r *recoveryCodeRepository) DeleteAll(ctx context.Context, userID uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %v", err)
+	}
+	return nil
+}
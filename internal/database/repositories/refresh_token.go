@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// Rotate marks the token as replaced. It returns false if the token had
+	// already been revoked, which callers must treat as reuse.
+	Rotate(ctx context.Context, id uuid.UUID, replacedBy uuid.UUID) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type refreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating refresh token: %v", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	token := models.RefreshToken{}
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens WHERE token_hash = $1`
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.ReplacedBy,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting refresh token: %v", err)
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) Rotate(ctx context.Context, id uuid.UUID, replacedBy uuid.UUID) (bool, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $1
+		WHERE id = $2 AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, replacedBy, id)
+	if err != nil {
+		return false, fmt.Errorf("error rotating refresh token: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, familyID); err != nil {
+		return fmt.Errorf("error revoking refresh token family: %v", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %v", err)
+	}
+	return nil
+}
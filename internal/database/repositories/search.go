@@ -10,7 +10,10 @@ import (
 )
 
 type SearchRepository interface {
-	SearchQuery(ctx context.Context, query string, userID uuid.UUID) (*models.SearchResult, error)
+	// SearchQuery finds the notes and cards of the user matching query. Cards
+	// are limited to the board boardID, or to boards that are not archived
+	// when it is nil.
+	SearchQuery(ctx context.Context, query string, userID uuid.UUID, boardID *uuid.UUID) (*models.SearchResult, error)
 }
 
 type searchRepository struct {
@@ -21,27 +24,33 @@ func NewSearchRepository(db *sql.DB) SearchRepository {
 	return &searchRepository{db: db}
 }
 
-func (s *searchRepository) SearchQuery(ctx context.Context, query string, userID uuid.UUID) (*models.SearchResult, error) {
+func (s *searchRepository) SearchQuery(ctx context.Context, query string, userID uuid.UUID, boardID *uuid.UUID) (*models.SearchResult, error) {
 	tsQuery := "to_tsquery('english', $1)"
 	notesQuery := `
-   	SELECT id, title, content, created_at, updated_at, user_id
+   	SELECT ` + noteColumns + `
    	FROM notes
    	WHERE user_id = $2 AND 
    	      (to_tsvector('english', title) @@ ` + tsQuery + ` OR 
    	       to_tsvector('english', content) @@ ` + tsQuery + `)
    	ORDER BY ts_rank(to_tsvector('english', title || ' ' || content), ` + tsQuery + `) DESC
    `
+	formattedQuery := formatTsQuery(query)
+	cardsArgs := []any{formattedQuery, userID}
+	boardFilter := " AND boards.archived_at IS NULL"
+	if boardID != nil {
+		cardsArgs = append(cardsArgs, *boardID)
+		boardFilter = " AND cards.board_id = $3"
+	}
 	cardsQuery := `
-   	SELECT id, title, description, status, created_at, updated_at, user_id
+   	SELECT ` + cardColumns + `
    	FROM cards
-   	WHERE user_id = $2 AND 
-   	      (to_tsvector('english', title) @@ ` + tsQuery + ` OR 
-   	       to_tsvector('english', description) @@ ` + tsQuery + `)
-   	ORDER BY ts_rank(to_tsvector('english', title || ' ' || description), ` + tsQuery + `) DESC
+   	JOIN boards ON boards.id = cards.board_id
+   	WHERE cards.user_id = $2` + boardFilter + ` AND 
+   	      (to_tsvector('english', cards.title) @@ ` + tsQuery + ` OR 
+   	       to_tsvector('english', cards.description) @@ ` + tsQuery + `)
+   	ORDER BY ts_rank(to_tsvector('english', cards.title || ' ' || cards.description), ` + tsQuery + `) DESC
    `
 
-	formattedQuery := formatTsQuery(query)
-
 	notesRows, err := s.db.QueryContext(ctx, notesQuery, formattedQuery, userID)
 	if err != nil {
 		return &models.SearchResult{}, err
@@ -55,9 +64,10 @@ func (s *searchRepository) SearchQuery(ctx context.Context, query string, userID
 			&note.ID,
 			&note.Title,
 			&note.Content,
+			&note.UserID,
 			&note.CreatedAt,
 			&note.UpdatedAt,
-			&note.UserID,
+			labelIDs{&note.LabelIDs},
 		); err != nil {
 			return &models.SearchResult{}, err
 		}
@@ -68,7 +78,7 @@ func (s *searchRepository) SearchQuery(ctx context.Context, query string, userID
 		return &models.SearchResult{}, err
 	}
 
-	cardsRows, err := s.db.QueryContext(ctx, cardsQuery, formattedQuery, userID)
+	cardsRows, err := s.db.QueryContext(ctx, cardsQuery, cardsArgs...)
 	if err != nil {
 		return &models.SearchResult{}, err
 	}
@@ -76,19 +86,11 @@ func (s *searchRepository) SearchQuery(ctx context.Context, query string, userID
 
 	var cards []models.Card
 	for cardsRows.Next() {
-		var card models.Card
-		if err := cardsRows.Scan(
-			&card.ID,
-			&card.Title,
-			&card.Description,
-			&card.Status,
-			&card.CreatedAt,
-			&card.UpdatedAt,
-			&card.UserID,
-		); err != nil {
+		card, err := scanCard(cardsRows)
+		if err != nil {
 			return &models.SearchResult{}, err
 		}
-		cards = append(cards, card)
+		cards = append(cards, *card)
 	}
 
 	if err := cardsRows.Err(); err != nil {
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	// GetActive returns the session if it is neither revoked nor expired.
+	GetActive(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Session, error)
+	GetAllActive(ctx context.Context, userID uuid.UUID) (*[]models.Session, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	// Extend moves the expiry of the session after its refresh token was
+	// rotated and records where the refresh came from.
+	Extend(ctx context.Context, id uuid.UUID, expiresAt time.Time, ip, userAgent string) error
+	Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+	// Prune deletes sessions that expired or were revoked before the given
+	// time, together with their refresh tokens.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, user_agent, ip, expires_at, last_seen_at, created_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, last_seen_at, created_at`
+	err := r.db.QueryRowContext(ctx, query, session.UserID, session.UserAgent, session.IP, session.ExpiresAt).Scan(&session.ID, &session.LastSeenAt, &session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+	return nil
+}
+
+const sessionColumns = `id, user_id, user_agent, ip, expires_at, revoked_at, last_seen_at, created_at`
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := models.Session{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IP,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+		&session.LastSeenAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetActive(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting session: %v", err)
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) GetAllActive(ctx context.Context, userID uuid.UUID) (*[]models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen_at DESC`
+	result, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sessions: %v", err)
+	}
+	defer result.Close()
+	sessions := []models.Session{}
+	for result.Next() {
+		session, err := scanSession(result)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning session: %v", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %v", err)
+	}
+	return &sessions, nil
+}
+
+func (r *sessionRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) Extend(ctx context.Context, id uuid.UUID, expiresAt time.Time, ip, userAgent string) error {
+	query := `
+		UPDATE sessions SET expires_at = $1, ip = $2, user_agent = $3, last_seen_at = CURRENT_TIMESTAMP
+		WHERE id = $4`
+	if _, err := r.db.ExecContext(ctx, query, expiresAt, ip, userAgent, id); err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("error revoking sessions: %v", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning sessions: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rowsAffected, nil
+}
@@ -7,10 +7,15 @@ import (
 	"fmt"
 	"rytr/internal/database/models"
 	"rytr/internal/utils"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+var ErrEmailTaken = errors.New("email already in use")
+
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
@@ -19,6 +24,44 @@ type UserRepository interface {
 	Update(ctx context.Context, user *models.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	ResetPassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	// SetPassword stores an already hashed password without checking the
+	// current one.
+	SetPassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	// UpdatePasswordHash replaces the stored hash with a new hash of the same
+	// password, unless the password was changed in the meantime.
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error
+	ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) error
+	CancelDeletion(ctx context.Context, id uuid.UUID) error
+	// PurgeScheduled hard-deletes every account whose deletion date has passed
+	// and returns how many were removed together with the avatar keys they
+	// had, so the caller can delete the blobs. Cards and notes go with them
+	// through ON DELETE CASCADE. Their audit events are kept without the
+	// email addresses in the metadata.
+	PurgeScheduled(ctx context.Context, now time.Time) (int64, []string, error)
+	// SetPendingTOTPSecret stores a secret that only becomes active once
+	// EnableTOTP is called.
+	SetPendingTOTPSecret(ctx context.Context, id uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, id uuid.UUID) error
+	DisableTOTP(ctx context.Context, id uuid.UUID) error
+	// Search lists users whose name or email contains query, newest first,
+	// and returns the total number of matches.
+	Search(ctx context.Context, query string, limit, offset int) (*[]models.UserSummary, int, error)
+	GetSummary(ctx context.Context, id uuid.UUID) (*models.UserSummary, error)
+	SetRole(ctx context.Context, id uuid.UUID, role string) error
+	SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error
+	// RequirePasswordReset blocks password logins until SetPassword is
+	// called.
+	RequirePasswordReset(ctx context.Context, id uuid.UUID) error
+	// ChangeEmail replaces the email of the user with an address that was
+	// confirmed by mail. It returns ErrEmailTaken if another account uses it.
+	ChangeEmail(ctx context.Context, id uuid.UUID, email string) error
+	// SetAvatar stores the key of a new avatar, or removes it if key is
+	// empty.
+	SetAvatar(ctx context.Context, id uuid.UUID, key string) error
+	// UseTOTPStep records the time step of an accepted code. It returns false
+	// if a code of the same or a later step was already used.
+	UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
 }
 
 type userRepository struct {
@@ -32,7 +75,7 @@ func NewUserRepository(db *sql.DB) UserRepository {
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
 		INSERT INTO users (first_name, last_name, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		VALUES ($1, $2, $3, NULLIF($4, ''), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING id, created_at, updated_at`
 	err := r.db.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Email, user.Password).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
@@ -41,24 +84,38 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	return nil
 }
 
-func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	user := models.User{}
-	query := `SELECT id, first_name, last_name, email, created_at, updated_at FROM users where id = $1`
-	err := r.db.QueryRowContext(ctx, query).Scan(user.ID, user.FirstName, user.LastName, user.Email, user.CreatedAt, user.UpdatedAt)
+// userColumns is the column list read by scanUser.
+const userColumns = `id, first_name, last_name, email, COALESCE(password, ''), email_verified_at, deletion_scheduled_at,
+	COALESCE(totp_secret, ''), totp_enabled_at, role, disabled_at, password_reset_required, avatar_key, created_at, updated_at`
 
-	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
-	}
-	if err != nil {
-		return nil, fmt.Errorf("error getting user: %v", err)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// userFields returns the scan destinations matching userColumns.
+func userFields(user *models.User) []any {
+	return []any{
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.Password,
+		&user.EmailVerifiedAt,
+		&user.DeletionScheduledAt,
+		&user.TOTPSecret,
+		&user.TOTPEnabledAt,
+		&user.Role,
+		&user.DisabledAt,
+		&user.PasswordResetRequired,
+		&user.AvatarKey,
+		&user.CreatedAt,
+		&user.UpdatedAt,
 	}
-	return &user, nil
 }
 
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+func scanUser(row rowScanner) (*models.User, error) {
 	user := models.User{}
-	query := `SELECT id, first_name, last_name, email, password, created_at, updated_at FROM users where email = $1`
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(userFields(&user)...)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("user not found")
 	}
@@ -68,6 +125,16 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 	return &user, nil
 }
 
+func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users where id = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users where email = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, email))
+}
+
 func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	// Update the user in the database
 	query := `
@@ -101,13 +168,28 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 }
 
 func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM users WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
 	return nil
 }
 
 func (r *userRepository) ResetPassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
 	// First verify that the old password matches
 	var storedPasswordHash string
-	query := `SELECT password FROM users WHERE id = $1`
+	query := `SELECT COALESCE(password, '') FROM users WHERE id = $1`
 
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(&storedPasswordHash)
 	if err != nil {
@@ -147,3 +229,274 @@ func (r *userRepository) ResetPassword(ctx context.Context, userID uuid.UUID, ol
 
 	return nil
 }
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP) WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) SetPassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password = $1, password_reset_required = FALSE, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, oldHash, newHash string) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`
+	if _, err := r.db.ExecContext(ctx, query, newHash, id, oldHash); err != nil {
+		return fmt.Errorf("failed to update password hash: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) ScheduleDeletion(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE users SET deletion_scheduled_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET deletion_scheduled_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) PurgeScheduled(ctx context.Context, now time.Time) (int64, []string, error) {
+	query := `
+		WITH purged AS (
+			DELETE FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1
+			RETURNING id, avatar_key
+		), anonymised AS (
+			UPDATE audit_events SET metadata = metadata - ARRAY['email', 'new_email']
+			WHERE user_id IN (SELECT id FROM purged)
+		)
+		SELECT avatar_key FROM purged`
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to purge users: %w", err)
+	}
+	defer rows.Close()
+	var (
+		purged     int64
+		avatarKeys []string
+	)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return 0, nil, fmt.Errorf("error scanning purged user: %w", err)
+		}
+		purged++
+		if key != "" {
+			avatarKeys = append(avatarKeys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to purge users: %w", err)
+	}
+	return purged, avatarKeys, nil
+}
+
+func (r *userRepository) SetPendingTOTPSecret(ctx context.Context, id uuid.UUID, secret string) error {
+	query := `UPDATE users SET totp_secret = $1, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $2`
+	if _, err := r.db.ExecContext(ctx, query, secret, id); err != nil {
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) EnableTOTP(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP WHERE id = $1 AND totp_secret IS NOT NULL`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("totp not set up")
+	}
+
+	return nil
+}
+
+func (r *userRepository) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`
+	result, err := r.db.ExecContext(ctx, query, step, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *userRepository) ChangeEmail(ctx context.Context, id uuid.UUID, email string) error {
+	query := `UPDATE users SET email = $1, email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, email, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// userSummaryColumns extends userColumns with the counts of a UserSummary.
+const userSummaryColumns = userColumns + `,
+	(SELECT COUNT(*) FROM cards WHERE cards.user_id = users.id),
+	(SELECT COUNT(*) FROM notes WHERE notes.user_id = users.id)`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *userRepository) Search(ctx context.Context, query string, limit, offset int) (*[]models.UserSummary, int, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	sqlQuery := `SELECT ` + userSummaryColumns + `, COUNT(*) OVER ()
+		FROM users
+		WHERE email ILIKE $1 OR first_name ILIKE $1 OR last_name ILIKE $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+	result, err := r.db.QueryContext(ctx, sqlQuery, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying users: %v", err)
+	}
+	defer result.Close()
+	users := []models.UserSummary{}
+	total := 0
+	for result.Next() {
+		user := models.UserSummary{}
+		dest := append(userFields(&user.User), &user.CardCount, &user.NoteCount, &total)
+		if err := result.Scan(dest...); err != nil {
+			return nil, 0, fmt.Errorf("error scanning user: %v", err)
+		}
+		users = append(users, user)
+	}
+	if err = result.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating users: %v", err)
+	}
+	return &users, total, nil
+}
+
+func (r *userRepository) GetSummary(ctx context.Context, id uuid.UUID) (*models.UserSummary, error) {
+	query := `SELECT ` + userSummaryColumns + ` FROM users WHERE id = $1`
+	user := models.UserSummary{}
+	dest := append(userFields(&user.User), &user.CardCount, &user.NoteCount)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %v", err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	query := `UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	return r.updateOne(ctx, "failed to set role", query, role, id)
+}
+
+func (r *userRepository) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	query := `UPDATE users SET disabled_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if disabled {
+		query = `UPDATE users SET disabled_at = COALESCE(disabled_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	}
+	return r.updateOne(ctx, "failed to update user", query, id)
+}
+
+func (r *userRepository) RequirePasswordReset(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET password_reset_required = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.updateOne(ctx, "failed to require password reset", query, id)
+}
+
+func (r *userRepository) SetAvatar(ctx context.Context, id uuid.UUID, key string) error {
+	query := `UPDATE users SET avatar_key = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	return r.updateOne(ctx, "failed to set avatar", query, key, id)
+}
+
+// updateOne runs an update of a single user and reports a missing user as
+// "user not found".
+func (r *userRepository) updateOne(ctx context.Context, msg string, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
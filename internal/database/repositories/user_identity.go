@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrUserIdentityNotFound = errors.New("user identity not found")
+
+type UserIdentityRepository interface {
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	GetAll(ctx context.Context, userID uuid.UUID) (*[]models.UserIdentity, error)
+	TouchLastLogin(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+}
+
+type userIdentityRepository struct {
+	db *sql.DB
+}
+
+func NewUserIdentityRepository(db *sql.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
+
+func (r *userIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at, created_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, last_login_at, created_at`
+	err := r.db.QueryRowContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).Scan(&identity.ID, &identity.LastLoginAt, &identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating user identity: %v", err)
+	}
+	return nil
+}
+
+const userIdentityColumns = `id, user_id, provider, subject, COALESCE(email, ''), last_login_at, created_at`
+
+func scanUserIdentity(row rowScanner) (*models.UserIdentity, error) {
+	identity := models.UserIdentity{}
+	err := row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.LastLoginAt,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *userIdentityRepository) GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+	identity, err := scanUserIdentity(r.db.QueryRowContext(ctx, query, provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserIdentityNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user identity: %v", err)
+	}
+	return identity, nil
+}
+
+func (r *userIdentityRepository) GetAll(ctx context.Context, userID uuid.UUID) (*[]models.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+	result, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user identities: %v", err)
+	}
+	defer result.Close()
+	identities := []models.UserIdentity{}
+	for result.Next() {
+		identity, err := scanUserIdentity(result)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user identity: %v", err)
+		}
+		identities = append(identities, *identity)
+	}
+	if err = result.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user identities: %v", err)
+	}
+	return &identities, nil
+}
+
+func (r *userIdentityRepository) TouchLastLogin(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("error updating user identity: %v", err)
+	}
+	return nil
+}
+
+func (r *userIdentityRepository) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting user identity: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserIdentityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"rytr/internal/database/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserSettingsRepository interface {
+	// Get returns the settings of a user, with the default of every setting
+	// the user never changed.
+	Get(ctx context.Context, userID uuid.UUID) (*models.UserSettings, error)
+	Save(ctx context.Context, userID uuid.UUID, settings *models.UserSettings) error
+}
+
+type userSettingsRepository struct {
+	db *sql.DB
+}
+
+func NewUserSettingsRepository(db *sql.DB) UserSettingsRepository {
+	return &userSettingsRepository{db: db}
+}
+
+func (r *userSettingsRepository) Get(ctx context.Context, userID uuid.UUID) (*models.UserSettings, error) {
+	settings := models.DefaultUserSettings()
+	var raw []byte
+	var updatedAt time.Time
+	query := `SELECT settings, updated_at FROM user_settings WHERE user_id = $1`
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&raw, &updatedAt)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user settings: %v", err)
+	}
+	// Stored settings only override the defaults, so settings added by a
+	// later version start out with their default.
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return nil, fmt.Errorf("error decoding user settings: %v", err)
+	}
+	settings.Version = models.SettingsVersion
+	settings.UpdatedAt = &updatedAt
+	return &settings, nil
+}
+
+func (r *userSettingsRepository) Save(ctx context.Context, userID uuid.UUID, settings *models.UserSettings) error {
+	settings.Version = models.SettingsVersion
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error encoding user settings: %v", err)
+	}
+	query := `
+		INSERT INTO user_settings (user_id, version, settings, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET version = $2, settings = $3, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`
+	if err := r.db.QueryRowContext(ctx, query, userID, settings.Version, raw).Scan(&settings.UpdatedAt); err != nil {
+		return fmt.Errorf("error saving user settings: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrUserTokenInvalid = errors.New("token is invalid or expired")
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	// Consume atomically marks a token as used and returns it. It fails with
+	// ErrUserTokenInvalid if the token is unknown, expired or already used.
+	Consume(ctx context.Context, purpose string, hash string) (*models.UserToken, error)
+	DeleteForUser(ctx context.Context, userID uuid.UUID, purpose string) error
+}
+
+type userTokenRepository struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, payload, expires_at, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, CURRENT_TIMESTAMP)
+		RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, token.UserID, token.Purpose, token.TokenHash, token.Payload, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating user token: %v", err)
+	}
+	return nil
+}
+
+func (r *userTokenRepository) Consume(ctx context.Context, purpose string, hash string) (*models.UserToken, error) {
+	token := models.UserToken{}
+	query := `
+		UPDATE user_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, user_id, purpose, token_hash, COALESCE(payload, ''), expires_at, used_at, created_at`
+	err := r.db.QueryRowContext(ctx, query, hash, purpose).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.Payload,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error consuming user token: %v", err)
+	}
+	return &token, nil
+}
+
+func (r *userTokenRepository) DeleteForUser(ctx context.Context, userID uuid.UUID, purpose string) error {
+	query := `DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2`
+	if _, err := r.db.ExecContext(ctx, query, userID, purpose); err != nil {
+		return fmt.Errorf("error deleting user tokens: %v", err)
+	}
+	return nil
+}
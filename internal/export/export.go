@@ -0,0 +1,295 @@
+// Package export builds the archives in which users take their data out of
+// rytr, and reads them back in.
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"rytr/internal/database/models"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version is the version of the Document format written by this package.
+// Version 1 had no columns and stored a status number with every card;
+// version 2 had no boards, version 3 no labels and version 4 no reminders.
+const Version = 5
+
+// DocumentFile is the name of the Document inside an archive.
+const DocumentFile = "rytr.json"
+
+// Board is a board as stored in an archive.
+type Board struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	ArchivedAt *time.Time `json:"archived_at"`
+}
+
+// Column is a board column as stored in an archive.
+type Column struct {
+	ID       uuid.UUID `json:"id"`
+	BoardID  uuid.UUID `json:"board_id"`
+	Name     string    `json:"name"`
+	Color    string    `json:"color"`
+	Position int       `json:"position"`
+	Done     bool      `json:"done"`
+}
+
+// Label is a label as stored in an archive.
+type Label struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Color string    `json:"color"`
+}
+
+// Reminder is a reminder of a card as stored in an archive.
+type Reminder struct {
+	MinutesBefore int `json:"minutes_before"`
+}
+
+// Card is a card as stored in an archive. Status is only set in documents
+// of version 1.
+type Card struct {
+	ID          uuid.UUID   `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	ColumnID    uuid.UUID   `json:"column_id"`
+	Status      *int8       `json:"status,omitempty"`
+	StartsAt    *time.Time  `json:"starts_at,omitempty"`
+	DueAt       *time.Time  `json:"due_at,omitempty"`
+	LabelIDs    []uuid.UUID `json:"label_ids,omitempty"`
+	Reminders   []Reminder  `json:"reminders,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Note is a note as stored in an archive. Content is the raw JSON written by
+// the editor.
+type Note struct {
+	ID        uuid.UUID       `json:"id"`
+	Title     string          `json:"title"`
+	Content   json.RawMessage `json:"content"`
+	LabelIDs  []uuid.UUID     `json:"label_ids,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Document holds the cards and notes of an account without any profile
+// data, so it can be loaded into another account.
+type Document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Boards     []Board   `json:"boards"`
+	Columns    []Column  `json:"columns"`
+	Labels     []Label   `json:"labels"`
+	Cards      []Card    `json:"cards"`
+	Notes      []Note    `json:"notes"`
+}
+
+// NewDocument converts the boards, columns, labels, cards with their
+// reminders and notes of a user.
+func NewDocument(boards []models.Board, columns []models.BoardColumn, labels []models.Label, cards []models.Card, reminders []models.CardReminder, notes []models.Note, now time.Time) *Document {
+	doc := &Document{Version: Version, ExportedAt: now.UTC(), Boards: []Board{}, Columns: []Column{}, Labels: []Label{}, Cards: []Card{}, Notes: []Note{}}
+	for _, b := range boards {
+		doc.Boards = append(doc.Boards, Board{ID: b.ID, Name: b.Name, ArchivedAt: b.ArchivedAt})
+	}
+	for _, c := range columns {
+		doc.Columns = append(doc.Columns, Column{
+			ID:       c.ID,
+			BoardID:  c.BoardID,
+			Name:     c.Name,
+			Color:    c.Color,
+			Position: c.Position,
+			Done:     c.Done,
+		})
+	}
+	for _, l := range labels {
+		doc.Labels = append(doc.Labels, Label{ID: l.ID, Name: l.Name, Color: l.Color})
+	}
+	cardReminders := map[uuid.UUID][]Reminder{}
+	for _, r := range reminders {
+		cardReminders[r.CardID] = append(cardReminders[r.CardID], Reminder{MinutesBefore: r.MinutesBefore})
+	}
+	for _, c := range cards {
+		doc.Cards = append(doc.Cards, Card{
+			ID:          c.ID,
+			Title:       c.Title,
+			Description: c.Description,
+			ColumnID:    c.ColumnID,
+			StartsAt:    c.StartsAt,
+			DueAt:       c.DueAt,
+			LabelIDs:    c.LabelIDs,
+			Reminders:   cardReminders[c.ID],
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+		})
+	}
+	for _, n := range notes {
+		doc.Notes = append(doc.Notes, Note{
+			ID:        n.ID,
+			Title:     n.Title,
+			Content:   rawContent(n.Content),
+			LabelIDs:  n.LabelIDs,
+			CreatedAt: n.CreatedAt,
+			UpdatedAt: n.UpdatedAt,
+		})
+	}
+	return doc
+}
+
+// rawContent keeps valid JSON as is and stores anything else as a string.
+func rawContent(content string) json.RawMessage {
+	if json.Valid([]byte(content)) {
+		return json.RawMessage(content)
+	}
+	raw, _ := json.Marshal(content)
+	return raw
+}
+
+// Write writes a ZIP archive with the profile, the Document and a Markdown
+// rendering of every card and note to w. The Markdown shows dates in loc.
+func Write(w io.Writer, user *models.User, doc *Document, loc *time.Location) error {
+	zw := zip.NewWriter(w)
+	if err := writeJSON(zw, "profile.json", user, doc.ExportedAt); err != nil {
+		return err
+	}
+	if err := writeJSON(zw, DocumentFile, doc, doc.ExportedAt); err != nil {
+		return err
+	}
+	labels := labelNames(doc.Labels)
+	if err := writeFile(zw, "cards.md", cardsMarkdown(doc, labels, loc), doc.ExportedAt); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, note := range doc.Notes {
+		name := "notes/" + noteFileName(note, names)
+		if err := writeFile(zw, name, noteMarkdown(note, labels, loc), note.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v any, modified time.Time) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("export: encoding %s: %v", name, err)
+	}
+	return writeFile(zw, name, string(data)+"\n", modified)
+}
+
+func writeFile(zw *zip.Writer, name, content string, modified time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("export: adding %s: %v", name, err)
+	}
+	if _, err := io.WriteString(f, content); err != nil {
+		return fmt.Errorf("export: writing %s: %v", name, err)
+	}
+	return nil
+}
+
+const markdownTimeFormat = "2006-01-02 15:04 MST"
+
+func labelNames(labels []Label) map[uuid.UUID]string {
+	names := make(map[uuid.UUID]string, len(labels))
+	for _, l := range labels {
+		names[l.ID] = l.Name
+	}
+	return names
+}
+
+// writeLabelsMarkdown lists the names of the labels with the given IDs.
+func writeLabelsMarkdown(b *strings.Builder, ids []uuid.UUID, labels map[uuid.UUID]string) {
+	var names []string
+	for _, id := range ids {
+		if name, ok := labels[id]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		fmt.Fprintf(b, "\nLabels: %s\n", strings.Join(names, ", "))
+	}
+}
+
+func cardsMarkdown(doc *Document, labels map[uuid.UUID]string, loc *time.Location) string {
+	byColumn := map[uuid.UUID][]Card{}
+	for _, c := range doc.Cards {
+		byColumn[c.ColumnID] = append(byColumn[c.ColumnID], c)
+	}
+	columns := slices.Clone(doc.Columns)
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].Position < columns[j].Position })
+
+	var b strings.Builder
+	b.WriteString("# Cards\n")
+	for _, board := range doc.Boards {
+		fmt.Fprintf(&b, "\n## %s", board.Name)
+		if board.ArchivedAt != nil {
+			b.WriteString(" (archived)")
+		}
+		b.WriteString("\n")
+		for _, column := range columns {
+			if column.BoardID != board.ID {
+				continue
+			}
+			fmt.Fprintf(&b, "\n### %s\n", column.Name)
+			for _, c := range byColumn[column.ID] {
+				writeCardMarkdown(&b, c, labels, loc)
+			}
+		}
+	}
+	return b.String()
+}
+
+func writeCardMarkdown(b *strings.Builder, c Card, labels map[uuid.UUID]string, loc *time.Location) {
+	fmt.Fprintf(b, "\n#### %s\n\n", c.Title)
+	fmt.Fprintf(b, "_Created %s, updated %s_\n", c.CreatedAt.In(loc).Format(markdownTimeFormat), c.UpdatedAt.In(loc).Format(markdownTimeFormat))
+	if c.StartsAt != nil {
+		fmt.Fprintf(b, "\nStarts %s\n", c.StartsAt.In(loc).Format(markdownTimeFormat))
+	}
+	if c.DueAt != nil {
+		fmt.Fprintf(b, "\nDue %s\n", c.DueAt.In(loc).Format(markdownTimeFormat))
+	}
+	writeLabelsMarkdown(b, c.LabelIDs, labels)
+	if desc := strings.TrimSpace(c.Description); desc != "" {
+		b.WriteString("\n" + desc + "\n")
+	}
+}
+
+func noteMarkdown(note Note, labels map[uuid.UUID]string, loc *time.Location) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", note.Title)
+	fmt.Fprintf(&b, "_Created %s, updated %s_\n", note.CreatedAt.In(loc).Format(markdownTimeFormat), note.UpdatedAt.In(loc).Format(markdownTimeFormat))
+	writeLabelsMarkdown(&b, note.LabelIDs, labels)
+	if body := NoteMarkdown(note.Content); body != "" {
+		b.WriteString("\n" + body)
+	}
+	return b.String()
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// noteFileName derives a readable, unique file name from the note title.
+func noteFileName(note Note, used map[string]bool) string {
+	slug := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(note.Title), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = "note"
+	}
+	name := slug + ".md"
+	if used[name] {
+		name = slug + "-" + note.ID.String()[:8] + ".md"
+	}
+	used[name] = true
+	return name
+}
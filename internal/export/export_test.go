@@ -0,0 +1,258 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"rytr/internal/database/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNoteMarkdownProseMirror(t *testing.T) {
+	content := `{"type":"doc","content":[
+		{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Plan"}]},
+		{"type":"paragraph","content":[{"type":"text","text":"Ship "},{"type":"text","text":"today","marks":[{"type":"bold"}]},{"type":"text","text":" via "},{"type":"text","text":"docs","marks":[{"type":"link","attrs":{"href":"https://example.com"}}]}]},
+		{"type":"bulletList","content":[
+			{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"one"}]}]},
+			{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"two"}]},
+				{"type":"orderedList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"nested"}]}]}]}]}
+		]},
+		{"type":"taskList","content":[{"type":"taskItem","attrs":{"checked":true},"content":[{"type":"paragraph","content":[{"type":"text","text":"done"}]}]}]},
+		{"type":"codeBlock","attrs":{"language":"go"},"content":[{"type":"text","text":"fmt.Println()"}]}
+	]}`
+	want := "## Plan\n\n" +
+		"Ship **today** via [docs](https://example.com)\n\n" +
+		"- one\n- two\n  1. nested\n\n" +
+		"- [x] done\n\n" +
+		"```go\nfmt.Println()\n```\n"
+	if got := NoteMarkdown(json.RawMessage(content)); got != want {
+		t.Errorf("unexpected markdown:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNoteMarkdownEditorJS(t *testing.T) {
+	content := `{"time":1700000000,"blocks":[
+		{"type":"header","data":{"text":"Title","level":1}},
+		{"type":"paragraph","data":{"text":"Hello <b>world</b> &amp; <a href=\"https://example.com\">friends</a>"}},
+		{"type":"list","data":{"style":"ordered","items":["first","second"]}},
+		{"type":"checklist","data":{"items":[{"text":"todo","checked":false}]}}
+	]}`
+	want := "# Title\n\n" +
+		"Hello **world** & [friends](https://example.com)\n\n" +
+		"1. first\n2. second\n\n" +
+		"- [ ] todo\n"
+	if got := NoteMarkdown(json.RawMessage(content)); got != want {
+		t.Errorf("unexpected markdown:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNoteMarkdownFallback(t *testing.T) {
+	if got := NoteMarkdown(json.RawMessage(`{}`)); got != "" {
+		t.Errorf("expected empty content to render as nothing, got %q", got)
+	}
+	if got := NoteMarkdown(json.RawMessage(`"plain text"`)); got != "plain text\n" {
+		t.Errorf("expected strings to render as is, got %q", got)
+	}
+	if got := NoteMarkdown(json.RawMessage(`{"foo":1}`)); got != "```json\n{\"foo\":1}\n```\n" {
+		t.Errorf("expected unknown documents to be kept as JSON, got %q", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &models.User{ID: uuid.New(), Email: "jane@example.com", FirstName: "Jane", Password: "secret-hash"}
+	boards := []models.Board{{ID: uuid.New(), Name: "Work"}, {ID: uuid.New(), Name: "Old", ArchivedAt: &now}}
+	columns := []models.BoardColumn{
+		{ID: uuid.New(), BoardID: boards[0].ID, Name: "Doing", Position: 1},
+		{ID: uuid.New(), BoardID: boards[0].ID, Name: "Backlog", Position: 0},
+		{ID: uuid.New(), BoardID: boards[1].ID, Name: "Someday", Position: 0},
+	}
+	due := now.Add(48 * time.Hour)
+	labels := []models.Label{{ID: uuid.New(), Name: "urgent", Color: "#d03030"}, {ID: uuid.New(), Name: "home"}}
+	cards := []models.Card{{ID: uuid.New(), Title: "Write tests", Description: "soon", ColumnID: columns[0].ID, DueAt: &due, LabelIDs: []uuid.UUID{labels[0].ID, labels[1].ID}, CreatedAt: now, UpdatedAt: now}}
+	reminders := []models.CardReminder{{ID: uuid.New(), CardID: cards[0].ID, MinutesBefore: 60}, {ID: uuid.New(), CardID: cards[0].ID, MinutesBefore: 0}}
+	notes := []models.Note{
+		{ID: uuid.New(), Title: "Ideas", Content: `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"hi"}]}]}`, LabelIDs: []uuid.UUID{labels[1].ID}, CreatedAt: now, UpdatedAt: now},
+		{ID: uuid.New(), Title: "Ideas", Content: `not json`, CreatedAt: now, UpdatedAt: now},
+	}
+	var buf bytes.Buffer
+	loc := time.FixedZone("CET", 3600)
+	if err := Write(&buf, user, NewDocument(boards, columns, labels, cards, reminders, notes, now), loc); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("error opening %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+	for _, name := range []string{"profile.json", DocumentFile, "cards.md", "notes/ideas.md", "notes/ideas-" + notes[1].ID.String()[:8] + ".md"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected archive to contain %s, got %v", name, files)
+		}
+	}
+	if strings.Contains(files["profile.json"], "secret-hash") {
+		t.Errorf("expected profile not to contain the password hash")
+	}
+	if !strings.Contains(files["cards.md"], "## Work\n\n### Backlog\n\n### Doing\n\n#### Write tests") {
+		t.Errorf("expected cards.md to contain the card in its column, got %q", files["cards.md"])
+	}
+	if !strings.Contains(files["cards.md"], "## Old (archived)\n\n### Someday\n") {
+		t.Errorf("expected cards.md to mark archived boards, got %q", files["cards.md"])
+	}
+	if !strings.Contains(files["cards.md"], "\nDue 2026-01-04 04:04 CET\n") {
+		t.Errorf("expected cards.md to contain the due date, got %q", files["cards.md"])
+	}
+	if !strings.Contains(files["cards.md"], "_Created 2026-01-02 04:04 CET") {
+		t.Errorf("expected dates in the given location, got %q", files["cards.md"])
+	}
+	if !strings.Contains(files["cards.md"], "\nLabels: home, urgent\n") {
+		t.Errorf("expected cards.md to list the labels of the card, got %q", files["cards.md"])
+	}
+	if !strings.Contains(files["notes/ideas.md"], "\nLabels: home\n") {
+		t.Errorf("expected the note to list its labels, got %q", files["notes/ideas.md"])
+	}
+	if !strings.Contains(files["notes/ideas.md"], "\nhi\n") {
+		t.Errorf("expected note to be rendered, got %q", files["notes/ideas.md"])
+	}
+
+	var doc Document
+	if err := json.Unmarshal([]byte(files[DocumentFile]), &doc); err != nil {
+		t.Fatalf("error decoding %s: %v", DocumentFile, err)
+	}
+	if doc.Version != Version || len(doc.Boards) != 2 || len(doc.Columns) != 3 || len(doc.Labels) != 2 || len(doc.Cards) != 1 || len(doc.Notes) != 2 {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if doc.Labels[0] != (Label{ID: labels[0].ID, Name: "urgent", Color: "#d03030"}) || len(doc.Cards[0].LabelIDs) != 2 || len(doc.Notes[0].LabelIDs) != 1 || doc.Notes[1].LabelIDs != nil {
+		t.Errorf("unexpected labels: %+v, cards %+v, notes %+v", doc.Labels, doc.Cards, doc.Notes)
+	}
+	if len(doc.Cards[0].Reminders) != 2 || doc.Cards[0].Reminders[0].MinutesBefore != 60 || doc.Cards[0].Reminders[1].MinutesBefore != 0 {
+		t.Errorf("unexpected reminders: %+v", doc.Cards[0].Reminders)
+	}
+	if string(doc.Notes[1].Content) != `"not json"` {
+		t.Errorf("expected invalid JSON content to be kept as a string, got %s", doc.Notes[1].Content)
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &models.User{ID: uuid.New(), Email: "jane@example.com"}
+	boards := []models.Board{{ID: uuid.New(), Name: "Work"}}
+	columns := []models.BoardColumn{{ID: uuid.New(), BoardID: boards[0].ID, Name: "Done", Done: true}}
+	labels := []models.Label{{ID: uuid.New(), Name: "urgent"}}
+	cards := []models.Card{{ID: uuid.New(), Title: "Write tests", ColumnID: columns[0].ID, LabelIDs: []uuid.UUID{labels[0].ID}, CreatedAt: now, UpdatedAt: now}}
+	notes := []models.Note{{ID: uuid.New(), Title: "Ideas", Content: `{"type":"doc"}`, LabelIDs: []uuid.UUID{labels[0].ID}, CreatedAt: now, UpdatedAt: now}}
+	var buf bytes.Buffer
+	if err := Write(&buf, user, NewDocument(boards, columns, labels, cards, nil, notes, now), time.UTC); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if !IsArchive(buf.Bytes()) {
+		t.Fatalf("expected the output of Write to be recognized as an archive")
+	}
+	doc, err := ReadArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 1<<20)
+	if err != nil {
+		t.Fatalf("ReadArchive returned error: %v", err)
+	}
+	if len(doc.Cards) != 1 || doc.Cards[0].ID != cards[0].ID || doc.Cards[0].ColumnID != columns[0].ID || len(doc.Cards[0].LabelIDs) != 1 || doc.Cards[0].LabelIDs[0] != labels[0].ID {
+		t.Errorf("unexpected cards: %+v", doc.Cards)
+	}
+	if len(doc.Labels) != 1 || doc.Labels[0].ID != labels[0].ID {
+		t.Errorf("unexpected labels: %+v", doc.Labels)
+	}
+	if len(doc.Notes) != 1 || doc.Notes[0].ID != notes[0].ID || len(doc.Notes[0].LabelIDs) != 1 {
+		t.Errorf("unexpected notes: %+v", doc.Notes)
+	}
+	if problems := doc.Validate(); len(problems) != 0 {
+		t.Errorf("expected an exported document to be valid, got %+v", problems)
+	}
+
+	if _, err := ReadArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 100); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	var empty bytes.Buffer
+	zip.NewWriter(&empty).Close()
+	if _, err := ReadArchive(bytes.NewReader(empty.Bytes()), int64(empty.Len()), 1<<20); err != ErrNoDocument {
+		t.Errorf("expected ErrNoDocument, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	label := uuid.NewString()
+	doc, err := ParseDocument([]byte(`{"version":6,"boards":[{"name":"` + strings.Repeat("x", MaxBoardNameLength+1) + `"}],"columns":[{"id":"` + uuid.NewString() + `","name":""}],` +
+		`"labels":[{"id":"` + label + `","name":"ok","color":"red"},{"id":"` + label + `","name":" "}],` +
+		`"cards":[{"title":"ok","label_ids":["` + label + `"]},{"title":"","starts_at":"2026-01-02T00:00:00Z","due_at":"2026-01-01T00:00:00Z","reminders":[{"minutes_before":-1},{"minutes_before":60},{"minutes_before":60}]}],` +
+		`"notes":[{"title":"` + strings.Repeat("x", MaxTitleLength+1) + `","label_ids":["` + uuid.NewString() + `"]}]}`))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+	var fields []string
+	for _, p := range doc.Validate() {
+		fields = append(fields, p.Field+":"+p.Code)
+	}
+	want := "version:unsupported boards[0].name:too_long boards[0].id:invalid columns[0].name:required columns[0].board_id:unknown_board labels[0].color:invalid labels[1].name:required labels[1].id:invalid cards[0].column_id:unknown_column cards[1].title:required cards[1].column_id:unknown_column cards[1].starts_at:after_due cards[1].reminders[0].minutes_before:out_of_range cards[1].reminders[2].minutes_before:duplicate notes[0].title:too_long notes[0].label_ids:unknown_label"
+	if got := strings.Join(fields, " "); got != want {
+		t.Errorf("got problems %q, want %q", got, want)
+	}
+	if _, err := ParseDocument([]byte(`{"cards":`)); err == nil {
+		t.Errorf("expected invalid JSON to be refused")
+	}
+}
+
+func TestParseDocumentVersion1(t *testing.T) {
+	doc, err := ParseDocument([]byte(`{"version":1,"cards":[{"title":"a","status":2},{"title":"b","status":1},{"title":"c","status":7},{"title":"d"}]}`))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+	if problems := doc.Validate(); len(problems) != 0 {
+		t.Fatalf("expected an upgraded document to be valid, got %+v", problems)
+	}
+	names := map[uuid.UUID]string{}
+	var order []string
+	for _, c := range doc.Columns {
+		names[c.ID] = c.Name
+		order = append(order, c.Name)
+	}
+	if got := strings.Join(order, ","); got != "To do,In progress,Done,Status 7" {
+		t.Errorf("unexpected columns %q", got)
+	}
+	for i, want := range []string{"Done", "In progress", "Status 7", "To do"} {
+		if got := names[doc.Cards[i].ColumnID]; got != want {
+			t.Errorf("expected card %d in column %q, got %q", i, want, got)
+		}
+	}
+	if doc.Version != Version {
+		t.Errorf("expected the document to be upgraded to version %d, got %d", Version, doc.Version)
+	}
+	if len(doc.Boards) != 1 || doc.Boards[0].Name != models.DefaultBoardName {
+		t.Errorf("expected the columns to be put on a single board, got %+v", doc.Boards)
+	}
+}
+
+func TestParseDocumentVersion3(t *testing.T) {
+	board, column := uuid.NewString(), uuid.NewString()
+	doc, err := ParseDocument([]byte(`{"version":3,"boards":[{"id":"` + board + `","name":"Work"}],"columns":[{"id":"` + column + `","board_id":"` + board + `","name":"To do"}],"cards":[{"title":"a","column_id":"` + column + `"}]}`))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+	if problems := doc.Validate(); len(problems) != 0 {
+		t.Fatalf("expected a document without labels to be valid, got %+v", problems)
+	}
+	if doc.Version != Version || len(doc.Labels) != 0 || doc.Cards[0].LabelIDs != nil {
+		t.Errorf("unexpected upgraded document: %+v", doc)
+	}
+}
@@ -0,0 +1,250 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"rytr/internal/database/models"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxTitleLength is the longest title accepted for a card or note.
+const MaxTitleLength = 255
+
+// MaxColumnNameLength is the longest name accepted for a column.
+const MaxColumnNameLength = 100
+
+// MaxBoardNameLength is the longest name accepted for a board.
+const MaxBoardNameLength = 100
+
+// MaxLabelNameLength is the longest name accepted for a label.
+const MaxLabelNameLength = 50
+
+// MaxReminderMinutes is the longest a reminder can fire before the due date.
+const MaxReminderMinutes = 4 * 7 * 24 * 60
+
+// labelColor is the format of label colors.
+var labelColor = regexp.MustCompile(`^(#[0-9a-fA-F]{6})?$`)
+
+// ErrNoDocument is returned for archives without a Document.
+var ErrNoDocument = errors.New("export: archive does not contain " + DocumentFile)
+
+// ErrTooLarge is returned for archives whose Document is larger than allowed.
+var ErrTooLarge = errors.New("export: " + DocumentFile + " is too large")
+
+// Problem explains why one field of a Document cannot be imported.
+type Problem struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ParseDocument decodes a Document from its JSON encoding and upgrades it
+// to the current Version.
+func ParseDocument(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("export: decoding %s: %v", DocumentFile, err)
+	}
+	if doc.Version == 1 {
+		upgradeStatuses(&doc)
+	}
+	if doc.Version == 2 {
+		upgradeBoards(&doc)
+	}
+	if doc.Version == 3 {
+		// Documents without labels need no changes.
+		doc.Version = 4
+	}
+	if doc.Version == 4 {
+		// Nor do documents without reminders.
+		doc.Version = 5
+	}
+	return &doc, nil
+}
+
+// upgradeBoards puts all columns of a document of version 2 on a single
+// board, the way the database was migrated.
+func upgradeBoards(doc *Document) {
+	board := Board{ID: uuid.New(), Name: models.DefaultBoardName}
+	doc.Boards = []Board{board}
+	for i := range doc.Columns {
+		doc.Columns[i].BoardID = board.ID
+	}
+	doc.Version = 3
+}
+
+// upgradeStatuses gives a document of version 1 columns in place of the
+// status numbers of its cards, the same way the database was migrated:
+// statuses 0 to 2 become the default columns and every other status a
+// column of its own.
+func upgradeStatuses(doc *Document) {
+	byStatus := map[int]uuid.UUID{}
+	addColumn := func(status int, name string, done bool) uuid.UUID {
+		id := uuid.New()
+		doc.Columns = append(doc.Columns, Column{ID: id, Name: name, Position: status, Done: done})
+		byStatus[status] = id
+		return id
+	}
+	doc.Columns = nil
+	for _, c := range models.DefaultBoardColumns() {
+		addColumn(c.Position, c.Name, c.Done)
+	}
+	for i := range doc.Cards {
+		var status int
+		if doc.Cards[i].Status != nil {
+			status = int(*doc.Cards[i].Status)
+		}
+		id, ok := byStatus[status]
+		if !ok {
+			id = addColumn(status, fmt.Sprintf("Status %d", status), false)
+		}
+		doc.Cards[i].ColumnID, doc.Cards[i].Status = id, nil
+	}
+	sort.SliceStable(doc.Columns, func(i, j int) bool { return doc.Columns[i].Position < doc.Columns[j].Position })
+	doc.Version = 2
+}
+
+// ReadArchive reads the Document out of a ZIP archive written by Write. It
+// returns ErrTooLarge instead of unpacking more than maxSize bytes, whatever
+// size the archive claims.
+func ReadArchive(r io.ReaderAt, size int64, maxSize int64) (*Document, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("export: reading archive: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != DocumentFile {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(maxSize) {
+			return nil, ErrTooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("export: opening %s: %v", DocumentFile, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("export: reading %s: %v", DocumentFile, err)
+		}
+		if int64(len(data)) > maxSize {
+			return nil, ErrTooLarge
+		}
+		return ParseDocument(data)
+	}
+	return nil, ErrNoDocument
+}
+
+// IsArchive reports whether data starts like a ZIP archive.
+func IsArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// Validate reports everything that keeps doc from being imported. Fields
+// are named after their JSON path, such as "cards[3].title".
+func (doc *Document) Validate() []Problem {
+	var problems []Problem
+	if doc.Version < 1 || doc.Version > Version {
+		problems = append(problems, Problem{"version", "unsupported", fmt.Sprintf("Version must be between 1 and %d", Version)})
+	}
+	boards := map[uuid.UUID]bool{}
+	for i, b := range doc.Boards {
+		field := fmt.Sprintf("boards[%d]", i)
+		switch {
+		case b.Name == "":
+			problems = append(problems, Problem{field + ".name", "required", "Name is required"})
+		case utf8.RuneCountInString(b.Name) > MaxBoardNameLength:
+			problems = append(problems, Problem{field + ".name", "too_long", fmt.Sprintf("Name must be at most %d characters", MaxBoardNameLength)})
+		}
+		if b.ID == uuid.Nil || boards[b.ID] {
+			problems = append(problems, Problem{field + ".id", "invalid", "ID must be unique"})
+		}
+		boards[b.ID] = b.ID != uuid.Nil
+	}
+	columns := map[uuid.UUID]bool{}
+	for i, c := range doc.Columns {
+		field := fmt.Sprintf("columns[%d]", i)
+		switch {
+		case c.Name == "":
+			problems = append(problems, Problem{field + ".name", "required", "Name is required"})
+		case utf8.RuneCountInString(c.Name) > MaxColumnNameLength:
+			problems = append(problems, Problem{field + ".name", "too_long", fmt.Sprintf("Name must be at most %d characters", MaxColumnNameLength)})
+		}
+		if c.ID == uuid.Nil || columns[c.ID] {
+			problems = append(problems, Problem{field + ".id", "invalid", "ID must be unique"})
+		}
+		if !boards[c.BoardID] {
+			problems = append(problems, Problem{field + ".board_id", "unknown_board", "Board must be one of the boards of the document"})
+		}
+		columns[c.ID] = true
+	}
+	labels := map[uuid.UUID]bool{}
+	for i, l := range doc.Labels {
+		field := fmt.Sprintf("labels[%d]", i)
+		switch {
+		case strings.TrimSpace(l.Name) == "":
+			problems = append(problems, Problem{field + ".name", "required", "Name is required"})
+		case utf8.RuneCountInString(strings.TrimSpace(l.Name)) > MaxLabelNameLength:
+			problems = append(problems, Problem{field + ".name", "too_long", fmt.Sprintf("Name must be at most %d characters", MaxLabelNameLength)})
+		}
+		if !labelColor.MatchString(l.Color) {
+			problems = append(problems, Problem{field + ".color", "invalid", "Color must look like #1a2b3c"})
+		}
+		if l.ID == uuid.Nil || labels[l.ID] {
+			problems = append(problems, Problem{field + ".id", "invalid", "ID must be unique"})
+		}
+		labels[l.ID] = l.ID != uuid.Nil
+	}
+	unknownLabels := func(field string, ids []uuid.UUID) {
+		for _, id := range ids {
+			if !labels[id] {
+				problems = append(problems, Problem{field, "unknown_label", "Labels must be labels of the document"})
+				return
+			}
+		}
+	}
+	for i, c := range doc.Cards {
+		field := fmt.Sprintf("cards[%d].title", i)
+		switch {
+		case c.Title == "":
+			problems = append(problems, Problem{field, "required", "Title is required"})
+		case utf8.RuneCountInString(c.Title) > MaxTitleLength:
+			problems = append(problems, Problem{field, "too_long", fmt.Sprintf("Title must be at most %d characters", MaxTitleLength)})
+		}
+		if !columns[c.ColumnID] {
+			problems = append(problems, Problem{fmt.Sprintf("cards[%d].column_id", i), "unknown_column", "Column must be one of the columns of the document"})
+		}
+		if c.StartsAt != nil && c.DueAt != nil && c.StartsAt.After(*c.DueAt) {
+			problems = append(problems, Problem{fmt.Sprintf("cards[%d].starts_at", i), "after_due", "Start date must not be after the due date"})
+		}
+		unknownLabels(fmt.Sprintf("cards[%d].label_ids", i), c.LabelIDs)
+		minutes := map[int]bool{}
+		for j, r := range c.Reminders {
+			field := fmt.Sprintf("cards[%d].reminders[%d].minutes_before", i, j)
+			switch {
+			case r.MinutesBefore < 0 || r.MinutesBefore > MaxReminderMinutes:
+				problems = append(problems, Problem{field, "out_of_range", fmt.Sprintf("Minutes before the due date must be between 0 and %d", MaxReminderMinutes)})
+			case minutes[r.MinutesBefore]:
+				problems = append(problems, Problem{field, "duplicate", "A card cannot have the same reminder twice"})
+			}
+			minutes[r.MinutesBefore] = true
+		}
+	}
+	for i, n := range doc.Notes {
+		if utf8.RuneCountInString(n.Title) > MaxTitleLength {
+			problems = append(problems, Problem{fmt.Sprintf("notes[%d].title", i), "too_long", fmt.Sprintf("Title must be at most %d characters", MaxTitleLength)})
+		}
+		unknownLabels(fmt.Sprintf("notes[%d].label_ids", i), n.LabelIDs)
+	}
+	return problems
+}
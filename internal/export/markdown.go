@@ -0,0 +1,288 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// NoteMarkdown renders the JSON content of a note as Markdown. Documents of
+// the ProseMirror/Tiptap ({"type": "doc"}) and Editor.js ({"blocks": [...]})
+// editors are understood; anything else is kept as a JSON code block so no
+// content is lost.
+func NoteMarkdown(content json.RawMessage) string {
+	var probe struct {
+		Type   string            `json:"type"`
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	trimmed := strings.TrimSpace(string(content))
+	if trimmed == "" || trimmed == "{}" || trimmed == "null" {
+		return ""
+	}
+	if json.Unmarshal(content, &probe) == nil {
+		switch {
+		case probe.Type == "doc":
+			var doc pmNode
+			if json.Unmarshal(content, &doc) == nil {
+				return strings.TrimSpace(renderProseMirror(&doc, "")) + "\n"
+			}
+		case probe.Blocks != nil:
+			return renderEditorJS(probe.Blocks)
+		}
+	}
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return strings.TrimSpace(text) + "\n"
+	}
+	return "```json\n" + trimmed + "\n```\n"
+}
+
+// pmNode is a node of a ProseMirror document.
+type pmNode struct {
+	Type    string         `json:"type"`
+	Text    string         `json:"text"`
+	Attrs   map[string]any `json:"attrs"`
+	Marks   []pmMark       `json:"marks"`
+	Content []pmNode       `json:"content"`
+}
+
+type pmMark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs"`
+}
+
+func (n *pmNode) attr(key string) string {
+	if v, ok := n.Attrs[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// renderProseMirror renders block nodes, prefixing every line with indent.
+func renderProseMirror(n *pmNode, indent string) string {
+	var b strings.Builder
+	switch n.Type {
+	case "doc":
+		for i := range n.Content {
+			b.WriteString(renderProseMirror(&n.Content[i], indent))
+		}
+	case "paragraph":
+		b.WriteString(indent + renderInline(n.Content) + "\n\n")
+	case "heading":
+		level := 1
+		fmt.Sscanf(n.attr("level"), "%d", &level)
+		level = min(max(level, 1), 6)
+		b.WriteString(indent + strings.Repeat("#", level) + " " + renderInline(n.Content) + "\n\n")
+	case "bulletList", "orderedList", "taskList":
+		for i := range n.Content {
+			item := &n.Content[i]
+			marker := "- "
+			switch {
+			case n.Type == "orderedList":
+				marker = fmt.Sprintf("%d. ", i+1)
+			case item.Type == "taskItem" && item.attr("checked") == "true":
+				marker = "- [x] "
+			case item.Type == "taskItem":
+				marker = "- [ ] "
+			}
+			b.WriteString(renderListItem(item, indent, marker))
+		}
+		b.WriteString("\n")
+	case "blockquote":
+		for i := range n.Content {
+			b.WriteString(renderProseMirror(&n.Content[i], indent+"> "))
+		}
+	case "codeBlock":
+		b.WriteString(indent + "```" + n.attr("language") + "\n")
+		for _, line := range strings.Split(renderInline(n.Content), "\n") {
+			b.WriteString(indent + line + "\n")
+		}
+		b.WriteString(indent + "```\n\n")
+	case "horizontalRule":
+		b.WriteString(indent + "---\n\n")
+	case "image":
+		b.WriteString(indent + fmt.Sprintf("![%s](%s)", n.attr("alt"), n.attr("src")) + "\n\n")
+	default:
+		if len(n.Content) > 0 {
+			for i := range n.Content {
+				b.WriteString(renderProseMirror(&n.Content[i], indent))
+			}
+		} else if n.Text != "" {
+			b.WriteString(indent + n.Text + "\n\n")
+		}
+	}
+	return b.String()
+}
+
+// renderListItem renders the first paragraph of item after marker and
+// indents everything else below it.
+func renderListItem(item *pmNode, indent, marker string) string {
+	var b strings.Builder
+	nested := indent + strings.Repeat(" ", len(marker))
+	for i := range item.Content {
+		child := &item.Content[i]
+		if i == 0 && child.Type == "paragraph" {
+			b.WriteString(indent + marker + renderInline(child.Content) + "\n")
+			continue
+		}
+		b.WriteString(strings.TrimRight(renderProseMirror(child, nested), "\n") + "\n")
+	}
+	if len(item.Content) == 0 {
+		b.WriteString(indent + marker + "\n")
+	}
+	return b.String()
+}
+
+func renderInline(nodes []pmNode) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.Type {
+		case "text":
+			b.WriteString(applyMarks(n.Text, n.Marks))
+		case "hardBreak":
+			b.WriteString("  \n")
+		case "image":
+			b.WriteString(fmt.Sprintf("![%s](%s)", n.attr("alt"), n.attr("src")))
+		default:
+			b.WriteString(renderInline(n.Content))
+		}
+	}
+	return b.String()
+}
+
+func applyMarks(text string, marks []pmMark) string {
+	for _, m := range marks {
+		switch m.Type {
+		case "bold", "strong":
+			text = "**" + text + "**"
+		case "italic", "em":
+			text = "*" + text + "*"
+		case "strike":
+			text = "~~" + text + "~~"
+		case "code":
+			text = "`" + text + "`"
+		case "link":
+			if href, ok := m.Attrs["href"].(string); ok {
+				text = "[" + text + "](" + href + ")"
+			}
+		}
+	}
+	return text
+}
+
+// ejsBlock is a block of an Editor.js document.
+type ejsBlock struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func renderEditorJS(raw []json.RawMessage) string {
+	var b strings.Builder
+	for _, r := range raw {
+		var block ejsBlock
+		if json.Unmarshal(r, &block) != nil {
+			continue
+		}
+		var data struct {
+			Text    string            `json:"text"`
+			Level   int               `json:"level"`
+			Style   string            `json:"style"`
+			Items   []json.RawMessage `json:"items"`
+			Code    string            `json:"code"`
+			Caption string            `json:"caption"`
+			URL     string            `json:"url"`
+			File    struct {
+				URL string `json:"url"`
+			} `json:"file"`
+		}
+		json.Unmarshal(block.Data, &data)
+		switch block.Type {
+		case "paragraph":
+			b.WriteString(inlineHTML(data.Text) + "\n\n")
+		case "header":
+			level := min(max(data.Level, 1), 6)
+			b.WriteString(strings.Repeat("#", level) + " " + inlineHTML(data.Text) + "\n\n")
+		case "list":
+			b.WriteString(renderEditorJSList(data.Items, data.Style == "ordered", ""))
+			b.WriteString("\n")
+		case "checklist":
+			for _, r := range data.Items {
+				var item struct {
+					Text    string `json:"text"`
+					Checked bool   `json:"checked"`
+				}
+				json.Unmarshal(r, &item)
+				box := "[ ]"
+				if item.Checked {
+					box = "[x]"
+				}
+				b.WriteString("- " + box + " " + inlineHTML(item.Text) + "\n")
+			}
+			b.WriteString("\n")
+		case "quote":
+			b.WriteString("> " + inlineHTML(data.Text) + "\n\n")
+		case "code":
+			b.WriteString("```\n" + data.Code + "\n```\n\n")
+		case "delimiter":
+			b.WriteString("---\n\n")
+		case "image":
+			url := data.File.URL
+			if url == "" {
+				url = data.URL
+			}
+			b.WriteString(fmt.Sprintf("![%s](%s)", inlineHTML(data.Caption), url) + "\n\n")
+		default:
+			if data.Text != "" {
+				b.WriteString(inlineHTML(data.Text) + "\n\n")
+			}
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// renderEditorJSList handles both plain string items and the nested
+// {"content": ..., "items": [...]} items of the nested list plugin.
+func renderEditorJSList(items []json.RawMessage, ordered bool, indent string) string {
+	var b strings.Builder
+	for i, r := range items {
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", i+1)
+		}
+		var text string
+		if json.Unmarshal(r, &text) == nil {
+			b.WriteString(indent + marker + inlineHTML(text) + "\n")
+			continue
+		}
+		var item struct {
+			Content string            `json:"content"`
+			Items   []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(r, &item)
+		b.WriteString(indent + marker + inlineHTML(item.Content) + "\n")
+		b.WriteString(renderEditorJSList(item.Items, ordered, indent+strings.Repeat(" ", len(marker))))
+	}
+	return b.String()
+}
+
+var (
+	htmlLink = regexp.MustCompile(`(?i)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	htmlTag  = regexp.MustCompile(`<[^>]+>`)
+	// inlineReplacer turns the inline markup Editor.js stores into Markdown.
+	inlineReplacer = strings.NewReplacer(
+		"<b>", "**", "</b>", "**", "<strong>", "**", "</strong>", "**",
+		"<i>", "*", "</i>", "*", "<em>", "*", "</em>", "*",
+		"<code>", "`", "</code>", "`", "<code class=\"inline-code\">", "`",
+		"<s>", "~~", "</s>", "~~",
+		"<br>", "  \n", "<br/>", "  \n", "<br />", "  \n",
+	)
+)
+
+func inlineHTML(s string) string {
+	s = htmlLink.ReplaceAllString(s, "[$2]($1)")
+	s = inlineReplacer.Replace(s)
+	s = htmlTag.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
@@ -0,0 +1,27 @@
+// Package jobs runs periodic background work inside the API process.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn every interval until ctx is cancelled. Errors are logged and
+// do not stop the schedule.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := fn(ctx); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
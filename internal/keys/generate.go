@@ -0,0 +1,62 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteNewKey generates a private key for alg ("EdDSA" or "RS256") and
+// stores it as <dir>/<kid>.pem.
+func WriteNewKey(dir, kid, alg string) error {
+	var (
+		priv crypto.Signer
+		err  error
+	)
+	switch alg {
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(dir, kid+".pem"), "PRIVATE KEY", der, 0o600)
+}
+
+// Retire replaces the private key <dir>/<kid>.pem by its public half, so the
+// key keeps verifying tokens but never signs again.
+func Retire(dir, kid string) error {
+	path := filepath.Join(dir, kid+".pem")
+	key, err := readKey(path)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public)
+	if err != nil {
+		return err
+	}
+	return writePEM(path, "PUBLIC KEY", der, 0o644)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,208 @@
+// Package keys manages the asymmetric keys JWTs are signed with. Keys live
+// as PEM files in a directory; the file name without extension is the key ID
+// ("kid"). Private keys sign and verify, public keys only verify, which is how
+// retired keys are kept around until the tokens they signed have expired.
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a single verification key and, if available, its private half.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Public  crypto.PublicKey
+	Private crypto.Signer
+}
+
+// KeySet holds every key tokens may be verified with and the one new tokens
+// are signed with. It is safe for concurrent use.
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	signing *Key
+
+	dir        string
+	signingKID string
+}
+
+// LoadDir reads all *.pem files in dir. New tokens are signed with the key
+// named signingKID or, if it is empty, with the private key whose ID sorts
+// last, so naming keys by date makes the newest one sign.
+func LoadDir(dir string, signingKID string) (*KeySet, error) {
+	ks := &KeySet{dir: dir, signingKID: signingKID}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload re-reads the key directory. The previous keys stay in use if the
+// directory cannot be loaded.
+func (ks *KeySet) Reload() error {
+	if ks.dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]*Key, len(paths))
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return fmt.Errorf("error loading %s: %v", path, err)
+		}
+		keys[key.ID] = key
+	}
+	signing, err := pickSigningKey(keys, ks.signingKID)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.signing = signing
+	return nil
+}
+
+// Generate returns a KeySet with a single in-memory Ed25519 key. Tokens
+// signed with it do not survive a restart, so it is only meant for
+// development.
+func Generate() (*KeySet, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{ID: "ephemeral", Method: jwt.SigningMethodEdDSA, Public: pub, Private: priv}
+	return &KeySet{keys: map[string]*Key{key.ID: key}, signing: key}, nil
+}
+
+func pickSigningKey(keys map[string]*Key, kid string) (*Key, error) {
+	if kid != "" {
+		key, ok := keys[kid]
+		if !ok || key.Private == nil {
+			return nil, fmt.Errorf("no private key with id %q", kid)
+		}
+		return key, nil
+	}
+	ids := make([]string, 0, len(keys))
+	for id, key := range keys {
+		if key.Private != nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no private key found")
+	}
+	sort.Strings(ids)
+	return keys[ids[len(ids)-1]], nil
+}
+
+func readKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		key.Private = signer
+		key.Public = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = parsed
+		key.Public = parsed.Public()
+	case "PUBLIC KEY":
+		key.Public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		if pub.Size() < 256 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+	return key, nil
+}
+
+// Sign signs claims with the current signing key and sets the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	key := ks.signing
+	ks.mu.RUnlock()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc resolves the verification key of a token from its kid header and
+// refuses algorithms that do not belong to that key.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// Methods lists the algorithms of all loaded keys, for jwt.WithValidMethods.
+func (ks *KeySet) Methods() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	methods := []string{}
+	for _, key := range ks.keys {
+		if !slices.Contains(methods, key.Method.Alg()) {
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+	return methods
+}
@@ -0,0 +1,91 @@
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signAndVerify(t *testing.T, signer *KeySet, verifier *KeySet) error {
+	t.Helper()
+	raw, err := signer.Sign(jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	if err != nil {
+		t.Fatalf("Sign returned error: %v", err)
+	}
+	_, err = jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, verifier.Keyfunc, jwt.WithValidMethods(verifier.Methods()))
+	return err
+}
+
+func TestLoadDirSignsWithNewestKey(t *testing.T) {
+	dir := t.TempDir()
+	for kid, alg := range map[string]string{"2024-01": "RS256", "2025-01": "EdDSA"} {
+		if err := WriteNewKey(dir, kid, alg); err != nil {
+			t.Fatalf("WriteNewKey returned error: %v", err)
+		}
+	}
+	ks, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("LoadDir returned error: %v", err)
+	}
+	raw, err := ks.Sign(jwt.RegisteredClaims{Subject: "user"})
+	if err != nil {
+		t.Fatalf("Sign returned error: %v", err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(raw, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("error parsing token: %v", err)
+	}
+	if token.Header["kid"] != "2025-01" || token.Method.Alg() != "EdDSA" {
+		t.Errorf("expected token signed by 2025-01 with EdDSA, got %v %s", token.Header["kid"], token.Method.Alg())
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyType != "RSA" || jwks.Keys[1].KeyType != "OKP" {
+		t.Errorf("unexpected JWKS: %+v", jwks)
+	}
+}
+
+func TestRetiredKeysStillVerify(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteNewKey(dir, "old", "EdDSA"); err != nil {
+		t.Fatalf("WriteNewKey returned error: %v", err)
+	}
+	before, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("LoadDir returned error: %v", err)
+	}
+
+	if err := WriteNewKey(dir, "new", "RS256"); err != nil {
+		t.Fatalf("WriteNewKey returned error: %v", err)
+	}
+	if err := Retire(dir, "old"); err != nil {
+		t.Fatalf("Retire returned error: %v", err)
+	}
+	after, err := LoadDir(dir, "new")
+	if err != nil {
+		t.Fatalf("LoadDir returned error: %v", err)
+	}
+	if err := signAndVerify(t, before, after); err != nil {
+		t.Errorf("expected token of retired key to verify, got %v", err)
+	}
+	if err := signAndVerify(t, after, after); err != nil {
+		t.Errorf("expected token of new key to verify, got %v", err)
+	}
+	if _, err := LoadDir(dir, "old"); err == nil {
+		t.Errorf("expected retired key to be rejected as signing key")
+	}
+}
+
+func TestUnknownKeyIsRejected(t *testing.T) {
+	a, _ := Generate()
+	b, _ := Generate()
+	b.keys["other"] = b.keys["ephemeral"]
+	delete(b.keys, "ephemeral")
+	if err := signAndVerify(t, a, b); err == nil {
+		t.Errorf("expected token signed with an unknown key to be rejected")
+	}
+}
@@ -0,0 +1,105 @@
+// Package lockout tracks failed attempts per key (an IP address, an account)
+// and temporarily locks keys out with exponential backoff.
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// State is what a Store remembers about a key.
+type State struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists attempt state. Implementations must make Increment atomic
+// so that concurrent failures are all counted.
+type Store interface {
+	// Get returns the state of key, or a zero State if nothing is recorded.
+	Get(ctx context.Context, key string) (State, error)
+	// Increment records a failure at now and returns the new failure count.
+	// Failures older than window are forgotten first.
+	Increment(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// LockUntil rejects key until t.
+	LockUntil(ctx context.Context, key string, t time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Prune drops keys that neither failed nor were locked after before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Policy configures when and for how long a key is locked.
+type Policy struct {
+	// Threshold is the number of failures allowed before the first lockout.
+	Threshold int
+	// BaseDelay is the first lockout duration. It doubles with every further
+	// failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Window is how long failures are remembered.
+	Window time.Duration
+}
+
+// Limiter applies a Policy to keys stored in a Store.
+type Limiter struct {
+	store  Store
+	policy Policy
+	prefix string
+	now    func() time.Time
+}
+
+// NewLimiter returns a Limiter whose keys are namespaced with prefix so that
+// several limiters can share one Store.
+func NewLimiter(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, prefix: prefix + ":", now: time.Now}
+}
+
+// Check returns how long key is still locked, or zero if it is not.
+func (l *Limiter) Check(ctx context.Context, key string) (time.Duration, error) {
+	state, err := l.store.Get(ctx, l.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	if wait := state.LockedUntil.Sub(l.now()); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt for key and locks it once the threshold is
+// reached. It returns the resulting lockout duration, zero if none.
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := l.now()
+	failures, err := l.store.Increment(ctx, l.prefix+key, now, l.policy.Window)
+	if err != nil {
+		return 0, err
+	}
+	delay := l.delay(failures)
+	if delay == 0 {
+		return 0, nil
+	}
+	if err := l.store.LockUntil(ctx, l.prefix+key, now.Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Reset forgets the failures of key, e.g. after a successful login.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, l.prefix+key)
+}
+
+func (l *Limiter) delay(failures int) time.Duration {
+	if failures < l.policy.Threshold {
+		return 0
+	}
+	delay := l.policy.BaseDelay
+	for i := l.policy.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+	return min(delay, l.policy.MaxDelay)
+}
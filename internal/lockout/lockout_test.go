@@ -0,0 +1,77 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := NewLimiter(NewMemoryStore(), "account", Policy{
+		Threshold: 3,
+		BaseDelay: time.Minute,
+		MaxDelay:  5 * time.Minute,
+		Window:    time.Hour,
+	})
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLimiterLocksWithExponentialBackoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}
+	for i, expected := range want {
+		delay, err := l.Fail(ctx, "jane@example.com")
+		if err != nil {
+			t.Fatalf("Fail returned error: %v", err)
+		}
+		if delay != expected {
+			t.Errorf("failure %d: expected delay %v, got %v", i+1, expected, delay)
+		}
+	}
+
+	wait, err := l.Check(ctx, "jane@example.com")
+	if err != nil || wait != 5*time.Minute {
+		t.Fatalf("expected key to be locked for 5m, got %v (%v)", wait, err)
+	}
+	if wait, _ := l.Check(ctx, "john@example.com"); wait != 0 {
+		t.Errorf("expected other keys not to be locked, got %v", wait)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if wait, _ := l.Check(ctx, "jane@example.com"); wait != 0 {
+		t.Errorf("expected lock to expire, still locked for %v", wait)
+	}
+}
+
+func TestLimiterForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+
+	l.Fail(ctx, "jane@example.com")
+	l.Fail(ctx, "jane@example.com")
+	now = now.Add(2 * time.Hour)
+	if delay, _ := l.Fail(ctx, "jane@example.com"); delay != 0 {
+		t.Errorf("expected failures outside the window to be forgotten, got delay %v", delay)
+	}
+}
+
+func TestLimiterReset(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(&now)
+
+	for i := 0; i < 3; i++ {
+		l.Fail(ctx, "jane@example.com")
+	}
+	if err := l.Reset(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+	if wait, _ := l.Check(ctx, "jane@example.com"); wait != 0 {
+		t.Errorf("expected reset key not to be locked, got %v", wait)
+	}
+}
@@ -0,0 +1,64 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps attempt state in process memory. It is only suitable for
+// single instance deployments.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key], nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[key]
+	if now.Sub(state.LastFailureAt) > window {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailureAt = now
+	s.states[key] = state
+	return state.Failures, nil
+}
+
+func (s *MemoryStore) LockUntil(ctx context.Context, key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[key]
+	state.LockedUntil = t
+	s.states[key] = state
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, state := range s.states {
+		if state.LastFailureAt.Before(before) && state.LockedUntil.Before(before) {
+			delete(s.states, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps attempt state in the login_attempts table so that all
+// instances of the API share it.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (State, error) {
+	state := State{}
+	var lockedUntil sql.NullTime
+	query := `SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1`
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&state.Failures, &state.LastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, fmt.Errorf("error getting login attempts: %v", err)
+	}
+	state.LockedUntil = lockedUntil.Time
+	return state, nil
+}
+
+func (s *PostgresStore) Increment(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	var failures int
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = $2
+		RETURNING failures`
+	if err := s.db.QueryRowContext(ctx, query, key, now, now.Add(-window)).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("error recording login attempt: %v", err)
+	}
+	return failures, nil
+}
+
+func (s *PostgresStore) LockUntil(ctx context.Context, key string, t time.Time) error {
+	query := `UPDATE login_attempts SET locked_until = $1 WHERE key = $2`
+	if _, err := s.db.ExecContext(ctx, query, t, key); err != nil {
+		return fmt.Errorf("error locking key: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("error resetting login attempts: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	query := `DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)`
+	if _, err := s.db.ExecContext(ctx, query, before); err != nil {
+		return fmt.Errorf("error pruning login attempts: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer drops every message as an .eml file into Dir instead of sending
+// it, which is handy during local development.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating mail dir: %v", err)
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString())
+	if err := os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg, now), 0o644); err != nil {
+		return fmt.Errorf("error writing mail: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New builds a Mailer from the environment. MAIL_DRIVER selects the
+// implementation: "smtp", "file" (the default) or "memory".
+func New() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "rytr <no-reply@localhost>"
+	}
+	switch driver := strings.ToLower(os.Getenv("MAIL_DRIVER")); driver {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %v", err)
+		}
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	case "", "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "tmp/mail"
+		}
+		return NewFileMailer(dir, from), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	msg := Message{To: "jane@example.com", Subject: "Hello", Body: "Hi there"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	got := m.Messages()
+	if len(got) != 1 || got[0] != msg {
+		t.Fatalf("expected %v, got %v", msg, got)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFileMailer(dir, "rytr <no-reply@example.com>")
+	msg := Message{To: "jane@example.com", Subject: "Verify", Body: "line one\nline two"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("error reading mail: %v", err)
+	}
+	for _, want := range []string{"To: jane@example.com\r\n", "Subject: Verify\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected mail to contain %q, got %q", want, data)
+		}
+	}
+}
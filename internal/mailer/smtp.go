@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer sends mail through an SMTP relay using PLAIN auth when a
+// username is configured.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %v", err)
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, from.Address, []string{msg.To}, format(m.From, msg, time.Now())); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	return nil
+}
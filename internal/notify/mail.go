@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"context"
+	"rytr/internal/mailer"
+)
+
+// MailNotifier emails notifications to their user.
+type MailNotifier struct {
+	mailer mailer.Mailer
+}
+
+func NewMailNotifier(m mailer.Mailer) *MailNotifier {
+	return &MailNotifier{mailer: m}
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, notification Notification) error {
+	body := notification.Body
+	if notification.Link != "" {
+		body += "\n\n" + notification.Link + "\n"
+	}
+	return n.mailer.Send(ctx, mailer.Message{To: notification.Email, Subject: notification.Title, Body: body})
+}
@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryNotifier keeps every notification in memory. It is meant for tests.
+type MemoryNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// Notifications returns a copy of the notifications sent so far.
+func (n *MemoryNotifier) Notifications() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Notification(nil), n.notifications...)
+}
@@ -0,0 +1,81 @@
+// Package notify tells users about things that happen to their data while
+// they are away, such as cards coming due.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"rytr/internal/mailer"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of notifications.
+const (
+	KindCardReminder = "card_reminder"
+)
+
+// Notification is a message for one user. Data holds the details of the
+// Kind, such as the ID of the card a reminder is for.
+type Notification struct {
+	Kind   string         `json:"kind"`
+	UserID uuid.UUID      `json:"user_id"`
+	Email  string         `json:"email"`
+	Title  string         `json:"title"`
+	Body   string         `json:"body"`
+	Link   string         `json:"link,omitempty"`
+	Data   map[string]any `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// New builds a Notifier from the environment. NOTIFY_DRIVERS is a comma
+// separated list of "email" (the default), "webhook" and "memory"; with
+// more than one every notification goes to all of them.
+func New(m mailer.Mailer) (Notifier, error) {
+	drivers := os.Getenv("NOTIFY_DRIVERS")
+	if drivers == "" {
+		drivers = "email"
+	}
+	var notifiers Multi
+	for _, driver := range strings.Split(drivers, ",") {
+		switch driver = strings.ToLower(strings.TrimSpace(driver)); driver {
+		case "email":
+			notifiers = append(notifiers, NewMailNotifier(m))
+		case "webhook":
+			url := os.Getenv("NOTIFY_WEBHOOK_URL")
+			if url == "" {
+				return nil, errors.New("NOTIFY_WEBHOOK_URL is required for the webhook notifier")
+			}
+			notifiers = append(notifiers, NewWebhookNotifier(url, os.Getenv("NOTIFY_WEBHOOK_SECRET")))
+		case "memory":
+			notifiers = append(notifiers, NewMemoryNotifier())
+		default:
+			return nil, fmt.Errorf("unknown notify driver %q", driver)
+		}
+	}
+	if len(notifiers) == 1 {
+		return notifiers[0], nil
+	}
+	return notifiers, nil
+}
+
+// Multi sends every notification to each of its notifiers.
+type Multi []Notifier
+
+// Notify tries every notifier and returns the errors of those that failed.
+func (m Multi) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
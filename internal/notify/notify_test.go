@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rytr/internal/mailer"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMailNotifier(t *testing.T) {
+	m := mailer.NewMemoryMailer()
+	n := Notification{Kind: KindCardReminder, Email: "jane@example.com", Title: "Due soon", Body: "Write tests", Link: "https://example.com/cards/1"}
+	if err := NewMailNotifier(m).Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	got := m.Messages()
+	if len(got) != 1 || got[0].To != n.Email || got[0].Subject != n.Title || !strings.Contains(got[0].Body, n.Link) {
+		t.Fatalf("unexpected messages: %+v", got)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Notification
+	var signature, want string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		signature, want = r.Header.Get(SignatureHeader), hex.EncodeToString(mac.Sum(nil))
+		json.Unmarshal(body, &received)
+		if received.Title == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	n := Notification{Kind: KindCardReminder, UserID: uuid.New(), Title: "Due soon", Data: map[string]any{"card_id": "1"}}
+	notifier := NewWebhookNotifier(srv.URL, "secret")
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	if received.UserID != n.UserID || received.Title != n.Title || received.Data["card_id"] != "1" {
+		t.Errorf("unexpected notification %+v", received)
+	}
+	if signature != want {
+		t.Errorf("expected signature %q, got %q", want, signature)
+	}
+	if err := notifier.Notify(context.Background(), Notification{Title: "fail"}); err == nil {
+		t.Errorf("expected an error status to be reported")
+	}
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, n Notification) error {
+	return errors.New("down")
+}
+
+func TestMulti(t *testing.T) {
+	memory := NewMemoryNotifier()
+	err := Multi{failingNotifier{}, memory}.Notify(context.Background(), Notification{Title: "hi"})
+	if err == nil {
+		t.Errorf("expected the failure to be reported")
+	}
+	if got := memory.Notifications(); len(got) != 1 || got[0].Title != "hi" {
+		t.Errorf("expected the other notifiers to still be called, got %+v", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("NOTIFY_DRIVERS", "")
+	if n, err := New(mailer.NewMemoryMailer()); err != nil {
+		t.Fatalf("New returned error: %v", err)
+	} else if _, ok := n.(*MailNotifier); !ok {
+		t.Errorf("expected email by default, got %T", n)
+	}
+	t.Setenv("NOTIFY_DRIVERS", "email, memory")
+	if n, err := New(mailer.NewMemoryMailer()); err != nil {
+		t.Fatalf("New returned error: %v", err)
+	} else if m, ok := n.(Multi); !ok || len(m) != 2 {
+		t.Errorf("expected both notifiers, got %T", n)
+	}
+	t.Setenv("NOTIFY_DRIVERS", "webhook")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "")
+	if _, err := New(mailer.NewMemoryMailer()); err == nil {
+		t.Errorf("expected the webhook driver to require a URL")
+	}
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body of a webhook
+// request, keyed with the secret of the WebhookNotifier.
+const SignatureHeader = "X-Rytr-Signature"
+
+// WebhookNotifier posts every notification as JSON to a URL, for example
+// to forward them to a chat or a push service.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("notify: encoding notification: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notify: building webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify: calling webhook: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notify: webhook answered %s", resp.Status)
+	}
+	return nil
+}
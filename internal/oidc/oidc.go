@@ -0,0 +1,158 @@
+// Package oidc signs users in with external OpenID Connect providers using
+// the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Identity is what a provider tells us about the user who signed in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+// Provider is one configured OpenID Connect issuer. Its discovery document is
+// fetched on first use so an unreachable provider does not stop the server
+// from starting.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	mu       sync.Mutex
+	provider *gooidc.Provider
+}
+
+var errMissingIDToken = errors.New("oidc: token response has no id_token")
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// LoadProviders reads the providers listed in OIDC_PROVIDERS. Every provider
+// <name> is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and optionally
+// OIDC_<NAME>_SCOPES.
+func LoadProviders() (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("oidc: invalid provider name %q", name)
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := &Provider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc: %sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*gooidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return p.provider, nil
+	}
+	provider, err := gooidc.NewProvider(ctx, p.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	p.provider = provider
+	return provider, nil
+}
+
+func (p *Provider) config(provider *gooidc.Provider) *oauth2.Config {
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+	}
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+}
+
+// AuthCodeURL returns the URL the user is sent to for signing in. The
+// verifier stays with us; only its S256 challenge is sent to the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.config(provider).AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems the authorization code and returns the identity from the
+// verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := p.config(provider).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errMissingIDToken
+	}
+	idToken, err := provider.Verifier(&gooidc.Config{ClientID: p.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+	var claims struct {
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		GivenName     string          `json:"given_name"`
+		FamilyName    string          `json:"family_name"`
+		Name          string          `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Subject: idToken.Subject,
+		Email:   claims.Email,
+		// Some providers send the flag as a string.
+		EmailVerified: string(claims.EmailVerified) == "true" || string(claims.EmailVerified) == `"true"`,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Name:          claims.Name,
+	}, nil
+}
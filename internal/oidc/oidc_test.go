@@ -0,0 +1,118 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"rytr/internal/oidc/oidctest"
+
+	"golang.org/x/oauth2"
+)
+
+const redirectURL = "http://localhost:8080/auth/oidc/test/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	t.Helper()
+	mock := oidctest.NewProvider(t)
+	return &Provider{
+		Name:         "test",
+		Issuer:       mock.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, mock
+}
+
+// signIn runs the browser part of the flow and returns the code and state
+// the provider redirected back with.
+func signIn(t *testing.T, p *Provider, mock *oidctest.Provider, state, nonce, verifier string) (string, string) {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+	callback := mock.Authorize(t, authURL)
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestExchangeReturnsIdentity(t *testing.T) {
+	p, mock := newTestProvider(t)
+	verifier := oauth2.GenerateVerifier()
+	code, state := signIn(t, p, mock, "state-1", "nonce-1", verifier)
+	if state != "state-1" {
+		t.Fatalf("expected state to be passed through, got %q", state)
+	}
+
+	identity, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	want := Identity{
+		Subject:       "test-subject",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		GivenName:     "Jane",
+		FamilyName:    "Doe",
+	}
+	if *identity != want {
+		t.Errorf("expected %+v, got %+v", want, *identity)
+	}
+}
+
+func TestExchangeAcceptsEmailVerifiedAsString(t *testing.T) {
+	p, mock := newTestProvider(t)
+	mock.Claims["email_verified"] = "true"
+	verifier := oauth2.GenerateVerifier()
+	code, _ := signIn(t, p, mock, "state", "nonce", verifier)
+
+	identity, err := p.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	if !identity.EmailVerified {
+		t.Errorf("expected email to be verified")
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	p, mock := newTestProvider(t)
+	code, _ := signIn(t, p, mock, "state", "nonce", oauth2.GenerateVerifier())
+
+	if _, err := p.Exchange(context.Background(), code, oauth2.GenerateVerifier(), "nonce"); err == nil {
+		t.Errorf("expected exchange with a different verifier to fail")
+	}
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	p, mock := newTestProvider(t)
+	verifier := oauth2.GenerateVerifier()
+	code, _ := signIn(t, p, mock, "state", "nonce", verifier)
+
+	if _, err := p.Exchange(context.Background(), code, verifier, "other-nonce"); err == nil {
+		t.Errorf("expected exchange with a different nonce to fail")
+	}
+}
+
+func TestLoadProviders(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "google, my-idp")
+	t.Setenv("OIDC_GOOGLE_ISSUER", "https://accounts.google.com")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "client")
+	t.Setenv("OIDC_GOOGLE_REDIRECT_URL", "https://api.example.com/auth/oidc/google/callback")
+	t.Setenv("OIDC_MY_IDP_ISSUER", "https://idp.example.com")
+	t.Setenv("OIDC_MY_IDP_CLIENT_ID", "client")
+	t.Setenv("OIDC_MY_IDP_REDIRECT_URL", "https://api.example.com/auth/oidc/my-idp/callback")
+	t.Setenv("OIDC_MY_IDP_SCOPES", "openid email")
+
+	providers, err := LoadProviders()
+	if err != nil {
+		t.Fatalf("LoadProviders returned error: %v", err)
+	}
+	if len(providers) != 2 || providers["google"] == nil || len(providers["my-idp"].Scopes) != 2 {
+		t.Errorf("unexpected providers: %+v", providers)
+	}
+
+	t.Setenv("OIDC_MY_IDP_CLIENT_ID", "")
+	if _, err := LoadProviders(); err == nil {
+		t.Errorf("expected missing client id to be rejected")
+	}
+}
@@ -0,0 +1,125 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachList tells whether a password is known from a data breach.
+type BreachList interface {
+	Contains(ctx context.Context, password string) (bool, error)
+}
+
+// prefixLength is the length of the hash prefix a lookup narrows the list to,
+// as in the k-anonymity range queries of Have I Been Pwned.
+const prefixLength = 5
+
+// BreachFile looks passwords up in a local copy of a breached password list:
+// upper-case hex SHA-1 hashes sorted in ascending order, one per line and
+// optionally followed by ":<count>", which is the format of the Have I Been
+// Pwned download. The file is never loaded into memory; a lookup binary
+// searches for the range of hashes sharing the first five characters and
+// only compares the password within that range.
+type BreachFile struct {
+	f    *os.File
+	size int64
+}
+
+// OpenBreachFile opens the sorted hash list at path.
+func OpenBreachFile(path string) (*BreachFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("password: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("password: %v", err)
+	}
+	return &BreachFile{f: f, size: info.Size()}, nil
+}
+
+func (b *BreachFile) Close() error {
+	return b.f.Close()
+}
+
+func (b *BreachFile) Contains(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix := hash[:prefixLength]
+
+	// Find the first offset whose next line is at or after the prefix.
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		mid := lo + (hi-lo)/2
+		key, _, err := b.lineAfter(mid)
+		if err != nil {
+			return false, err
+		}
+		if key != "" && key[:min(len(key), prefixLength)] < prefix {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	_, start, err := b.lineAfter(lo)
+	if err != nil {
+		return false, err
+	}
+	scanner := bufio.NewScanner(io.NewSectionReader(b.f, start, b.size-start))
+	for scanner.Scan() {
+		key := lineKey(scanner.Text())
+		if !strings.HasPrefix(key, prefix) {
+			return false, nil
+		}
+		if key == hash {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("password: reading breach file: %v", err)
+	}
+	return false, nil
+}
+
+// lineAfter returns the hash of the first line starting at or after offset
+// together with the line's start. The hash is empty at the end of the file.
+func (b *BreachFile) lineAfter(offset int64) (string, int64, error) {
+	start := offset
+	if offset > 0 {
+		// Unless offset directly follows a newline it points into a line
+		// that has to be skipped.
+		r := bufio.NewReader(io.NewSectionReader(b.f, offset-1, b.size-offset+1))
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return "", b.size, nil
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("password: reading breach file: %v", err)
+		}
+		start = offset - 1 + int64(len(skipped))
+	}
+	if start >= b.size {
+		return "", b.size, nil
+	}
+	line, err := bufio.NewReader(io.NewSectionReader(b.f, start, b.size-start)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, fmt.Errorf("password: reading breach file: %v", err)
+	}
+	return lineKey(line), start, nil
+}
+
+func lineKey(line string) string {
+	key, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(key)
+}
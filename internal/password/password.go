@@ -0,0 +1,133 @@
+// Package password decides which passwords users may choose.
+package password
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"rytr/internal/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codes of the rules a password can break.
+const (
+	CodeTooShort = "too_short"
+	CodeTooLong  = "too_long"
+	CodeBanned   = "banned"
+	CodeBreached = "breached"
+)
+
+// Violation is one rule a password breaks.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Policy is the set of rules every new password is checked against. Lengths
+// are counted in characters, not bytes.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Banned holds lower-cased passwords that are refused outright.
+	Banned map[string]struct{}
+	// Breached is consulted last and may be nil.
+	Breached BreachList
+}
+
+// defaultBanned are passwords that are long enough to pass the length check but
+// guessed first by every attacker.
+var defaultBanned = []string{
+	"password", "password1", "password123", "passw0rd", "12345678", "123456789",
+	"1234567890", "87654321", "11111111", "00000000", "qwertyuiop", "qwerty123",
+	"iloveyou", "sunshine", "princess", "football", "baseball", "welcome1",
+	"letmein1", "trustno1", "superman", "starwars", "abcd1234", "rytr1234",
+}
+
+// Validate returns every rule password breaks, or nil if it is acceptable.
+// The error is only set when the breached password list cannot be read.
+func (p *Policy) Validate(ctx context.Context, password string) ([]Violation, error) {
+	var violations []Violation
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("must be at most %d characters long", p.MaxLength),
+		})
+	}
+	if _, ok := p.Banned[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{
+			Code:    CodeBanned,
+			Message: "is too common, choose a less predictable password",
+		})
+	}
+	// Passwords that are already refused are not worth a lookup.
+	if len(violations) == 0 && p.Breached != nil {
+		breached, err := p.Breached.Contains(ctx, password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, Violation{
+				Code:    CodeBreached,
+				Message: "appeared in a data breach, choose a different password",
+			})
+		}
+	}
+	return violations, nil
+}
+
+// LoadPolicy builds the policy from PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH,
+// PASSWORD_BANNED_FILE (one password per line, added to a built-in list) and
+// BREACHED_PASSWORDS_FILE.
+func LoadPolicy() (*Policy, error) {
+	p := &Policy{
+		MinLength: utils.GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength: utils.GetEnvInt("PASSWORD_MAX_LENGTH", 128),
+		Banned:    map[string]struct{}{},
+	}
+	if p.MaxLength > 0 && p.MaxLength < p.MinLength {
+		return nil, fmt.Errorf("password: PASSWORD_MAX_LENGTH %d is below PASSWORD_MIN_LENGTH %d", p.MaxLength, p.MinLength)
+	}
+	for _, banned := range defaultBanned {
+		p.Banned[banned] = struct{}{}
+	}
+	if path := os.Getenv("PASSWORD_BANNED_FILE"); path != "" {
+		if err := p.loadBanned(path); err != nil {
+			return nil, err
+		}
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		list, err := OpenBreachFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p.Breached = list
+	}
+	return p, nil
+}
+
+func (p *Policy) loadBanned(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("password: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.Banned[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("password: reading %s: %v", path, err)
+	}
+	return nil
+}
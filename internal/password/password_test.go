@@ -0,0 +1,121 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachFile writes the hashes of passwords plus filler hashes, sorted
+// like the real download.
+func writeBreachFile(t *testing.T, passwords ...string) string {
+	t.Helper()
+	var lines []string
+	for _, p := range passwords {
+		lines = append(lines, sha1Hex(p)+":42")
+	}
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(fmt.Sprintf("filler-%d", i)), i))
+	}
+	slices.Sort(lines)
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatalf("error writing breach file: %v", err)
+	}
+	return path
+}
+
+func codes(violations []Violation) []string {
+	var c []string
+	for _, v := range violations {
+		c = append(c, v.Code)
+	}
+	return c
+}
+
+func TestPolicyValidate(t *testing.T) {
+	list, err := OpenBreachFile(writeBreachFile(t, "correcthorse"))
+	if err != nil {
+		t.Fatalf("OpenBreachFile returned error: %v", err)
+	}
+	defer list.Close()
+	p := &Policy{
+		MinLength: 8,
+		MaxLength: 12,
+		Banned:    map[string]struct{}{"password1": {}},
+		Breached:  list,
+	}
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"s3cure-pass", nil},
+		{"short", []string{CodeTooShort}},
+		{"ünïcödé", []string{CodeTooShort}},
+		{"much-too-long-password", []string{CodeTooLong}},
+		{"PASSWORD1", []string{CodeBanned}},
+		{"correcthorse", []string{CodeBreached}},
+	}
+	for _, tt := range tests {
+		violations, err := p.Validate(context.Background(), tt.password)
+		if err != nil {
+			t.Fatalf("Validate(%q) returned error: %v", tt.password, err)
+		}
+		if got := codes(violations); !slices.Equal(got, tt.want) {
+			t.Errorf("Validate(%q): expected %v, got %v", tt.password, tt.want, got)
+		}
+	}
+}
+
+func TestBreachFileContains(t *testing.T) {
+	breached := []string{"hunter2", "letmein", "monkey", "dragon"}
+	list, err := OpenBreachFile(writeBreachFile(t, breached...))
+	if err != nil {
+		t.Fatalf("OpenBreachFile returned error: %v", err)
+	}
+	defer list.Close()
+
+	for _, p := range breached {
+		if ok, err := list.Contains(context.Background(), p); err != nil || !ok {
+			t.Errorf("expected %q to be breached, got %v (%v)", p, ok, err)
+		}
+	}
+	for i := 0; i < 500; i += 97 {
+		p := fmt.Sprintf("filler-%d", i)
+		if ok, err := list.Contains(context.Background(), p); err != nil || !ok {
+			t.Errorf("expected %q to be breached, got %v (%v)", p, ok, err)
+		}
+	}
+	for _, p := range []string{"", "not in the list", "filler-500"} {
+		if ok, err := list.Contains(context.Background(), p); err != nil || ok {
+			t.Errorf("expected %q not to be breached, got %v (%v)", p, ok, err)
+		}
+	}
+}
+
+func TestBreachFileEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("error writing breach file: %v", err)
+	}
+	list, err := OpenBreachFile(path)
+	if err != nil {
+		t.Fatalf("OpenBreachFile returned error: %v", err)
+	}
+	defer list.Close()
+	if ok, err := list.Contains(context.Background(), "hunter2"); err != nil || ok {
+		t.Errorf("expected empty list not to contain anything, got %v (%v)", ok, err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package rank generates keys that order items by plain string comparison,
+// so an item can be moved between two others by giving it a new key without
+// touching any other item.
+//
+// A key is a fraction between 0 and 1 written in base 62 without the
+// leading "0.", using the digits 0-9, A-Z and a-z in that (ASCII) order.
+// Keys never end in the digit 0, since "V" and "V0" would be equal
+// fractions with no key between them. In the database keys must be compared
+// byte by byte, which is the "C" collation in PostgreSQL.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// MaxLength is the length above which keys should be rebalanced. Inserting
+// again and again at the same spot adds a digit about every sixth time.
+const MaxLength = 32
+
+var (
+	// ErrInvalidKey is returned for a key with other characters than the
+	// digits or ending in 0.
+	ErrInvalidKey = errors.New("rank: invalid key")
+	// ErrOrder is returned by Between if there is no key between its
+	// arguments because they are equal or in the wrong order.
+	ErrOrder = errors.New("rank: keys out of order")
+)
+
+// Valid reports whether key is a well formed key.
+func Valid(key string) bool {
+	if key == "" || key[len(key)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Between returns a key that sorts after a and before b. An empty a stands
+// for the start and an empty b for the end, so Between("", "") is the
+// first key of an empty list and Between(last, "") appends to a list.
+func Between(a, b string) (string, error) {
+	if (a != "" && !Valid(a)) || (b != "" && !Valid(b)) {
+		return "", ErrInvalidKey
+	}
+	if b != "" && a >= b {
+		return "", ErrOrder
+	}
+	return midpoint(a, b), nil
+}
+
+// midpoint returns a key between a and b, which are valid and in order.
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the prefix both keys share, padding a with zeros.
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			if n > len(a) {
+				return b[:n] + midpoint("", b[n:])
+			}
+			return b[:n] + midpoint(a[n:], b[n:])
+		}
+	}
+	lo := strings.IndexByte(digits, digitAt(a, 0))
+	hi := base
+	if b != "" {
+		hi = strings.IndexByte(digits, b[0])
+	}
+	if hi-lo > 1 {
+		return string(digits[(lo+hi+1)/2])
+	}
+	// The first digits are consecutive.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[lo]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
+
+// Spread returns n keys in ascending order, evenly spaced and as short as
+// possible while leaving room for about base insertions between any two of
+// them without growing longer.
+func Spread(n int) []string {
+	keys := make([]string, 0, n)
+	if n <= 0 {
+		return keys
+	}
+	width, size := 1, uint64(base)
+	for size/uint64(n+1) < uint64(base) {
+		width++
+		size *= uint64(base)
+	}
+	step := size / uint64(n+1)
+	buf := make([]byte, width)
+	for i := 1; i <= n; i++ {
+		v := step * uint64(i)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = digits[v%uint64(base)]
+			v /= uint64(base)
+		}
+		keys = append(keys, strings.TrimRight(string(buf), digits[:1]))
+	}
+	return keys
+}
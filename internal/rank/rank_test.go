@@ -0,0 +1,81 @@
+package rank
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"", "", "V"},
+		{"V", "", "l"},
+		{"", "V", "G"},
+		{"V", "W", "VV"},
+		{"V", "V1", "V0V"},
+		{"z", "", "zV"},
+		{"", "1", "0V"},
+		{"A1", "A2", "A1V"},
+		{"A", "AV", "AG"},
+	}
+	for _, tt := range tests {
+		got, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) returned error: %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("Between(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, keys := range [][2]string{{"V", "V"}, {"W", "V"}, {"V0", ""}, {"", "a-b"}} {
+		if _, err := Between(keys[0], keys[1]); err == nil {
+			t.Errorf("expected Between(%q, %q) to fail", keys[0], keys[1])
+		}
+	}
+}
+
+func TestBetweenRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		at := r.Intn(len(keys) + 1)
+		var a, b string
+		if at > 0 {
+			a = keys[at-1]
+		}
+		if at < len(keys) {
+			b = keys[at]
+		}
+		key, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) returned error: %v", a, b, err)
+		}
+		if !Valid(key) || key <= a || (b != "" && key >= b) {
+			t.Fatalf("Between(%q, %q) = %q is not between them", a, b, key)
+		}
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 61, 62, 1000, 100000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		if !sort.StringsAreSorted(keys) {
+			t.Errorf("Spread(%d) is not sorted", n)
+		}
+		for i, key := range keys {
+			if !Valid(key) || (i > 0 && key == keys[i-1]) {
+				t.Fatalf("Spread(%d) returned invalid key %q", n, key)
+			}
+		}
+	}
+	if keys := Spread(1); keys[0] != "V" {
+		t.Errorf("expected a single key in the middle, got %q", keys[0])
+	}
+}
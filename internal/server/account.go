@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/jobs"
+	"rytr/internal/mailer"
+	"rytr/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	accountDeletionGracePeriod = utils.GetEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+	accountPurgeInterval       = utils.GetEnvDuration("ACCOUNT_PURGE_INTERVAL", time.Hour)
+)
+
+// StartBackgroundJobs starts the periodic jobs of the server. They stop when
+// ctx is cancelled.
+func (s *FiberServer) StartBackgroundJobs(ctx context.Context) {
+	jobs.Every(ctx, "account-purge", accountPurgeInterval, s.purgeDeletedAccounts)
+	jobs.Every(ctx, "login-attempts-prune", time.Hour, s.pruneLoginAttempts)
+	jobs.Every(ctx, "sessions-prune", time.Hour, s.pruneSessions)
+	jobs.Every(ctx, "exports-prune", time.Hour, s.pruneExports)
+	jobs.Every(ctx, "card-reminders", reminderInterval, s.sendDueReminders)
+	jobs.Every(ctx, "signing-keys-reload", utils.GetEnvDuration("JWT_KEY_RELOAD_INTERVAL", time.Minute), func(ctx context.Context) error {
+		return s.keys.Reload()
+	})
+}
+
+func (s *FiberServer) purgeDeletedAccounts(ctx context.Context) error {
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	purged, avatarKeys, err := userRepo.PurgeScheduled(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, key := range avatarKeys {
+		s.deleteAvatarBlobs(ctx, key)
+	}
+	if purged > 0 {
+		log.Printf("purged %d deleted accounts", purged)
+	}
+	return nil
+}
+
+// deleteAccount schedules the account for deletion after the grace period.
+// Logging in again before then cancels the deletion.
+func (s *FiberServer) deleteAccount(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+
+	var req dto.PasswordConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if status, message := s.reauthenticate(c, currentUser, req.Password, req.Code, req.RecoveryCode); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"message": message})
+	}
+
+	deleteAt := time.Now().Add(accountDeletionGracePeriod)
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.ScheduleDeletion(c.Context(), currentUser.ID, deleteAt); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete account"})
+	}
+	if err := s.revokeAllSessions(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke sessions"})
+	}
+	s.audit(c, models.AuditDeletionScheduled, currentUser.ID, fiber.Map{"deletion_scheduled_at": deleteAt})
+	s.sendMail(mailer.Message{
+		To:      currentUser.Email,
+		Subject: "Your account is scheduled for deletion",
+		Body: fmt.Sprintf("Hi %s,\n\nYour rytr account and all of its cards and notes will be permanently deleted on %s.\nLog in again before then if you want to keep it.\n",
+			currentUser.FirstName, deleteAt.UTC().Format(time.RFC1123)),
+	})
+
+	return c.JSON(fiber.Map{
+		"message":               "Account scheduled for deletion, log in again to cancel",
+		"deletion_scheduled_at": deleteAt,
+	})
+}
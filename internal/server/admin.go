@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	adminUsersDefaultLimit = 50
+	adminUsersMaxLimit     = 200
+)
+
+// requireRole only lets users with one of roles through.
+func requireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !slices.Contains(roles, userFromContext(c).Role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Insufficient permissions"})
+		}
+		return c.Next()
+	}
+}
+
+func bToMb(b uint64) uint64 {
+	return b / 1024 / 1024
+}
+
+// getMemoryStats is used to monitor memory.
+func (s *FiberServer) getMemoryStats(c *fiber.Ctx) error {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	memoryInfo := fmt.Sprintf("Alloc = %v MiB, TotalAlloc = %v MiB, Sys = %v MiB, NumGC = %v",
+		bToMb(m.Alloc), bToMb(m.TotalAlloc), bToMb(m.Sys), m.NumGC)
+	return c.SendString(memoryInfo)
+}
+
+// pageParams reads limit and offset query parameters.
+func pageParams(c *fiber.Ctx, defaultLimit, maxLimit int) (int, int) {
+	limit := c.QueryInt("limit", defaultLimit)
+	if limit <= 0 || limit > maxLimit {
+		limit = defaultLimit
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func (s *FiberServer) getAdminUsers(c *fiber.Ctx) error {
+	limit, offset := pageParams(c, adminUsersDefaultLimit, adminUsersMaxLimit)
+	repo := repositories.NewUserRepository(s.db.DB())
+	users, total, err := repo.Search(c.Context(), c.Query("q"), limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch users"})
+	}
+	return c.JSON(fiber.Map{"users": users, "total": total, "limit": limit, "offset": offset})
+}
+
+func (s *FiberServer) getAdminUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewUserRepository(s.db.DB())
+	user, err := repo.GetSummary(c.Context(), id)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch user"})
+	}
+	return c.JSON(fiber.Map{"user": user})
+}
+
+// forbidSelf refuses admin actions on the admin's own account, so nobody
+// can lock themselves out.
+func forbidSelf(c *fiber.Ctx) error {
+	if id, err := uuid.Parse(c.Params("id")); err == nil && id == userFromContext(c).ID {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "You cannot change your own account here"})
+	}
+	return c.Next()
+}
+
+func (s *FiberServer) setUserDisabled(disabled bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := uuid.Parse(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+		}
+		repo := repositories.NewUserRepository(s.db.DB())
+		if err := repo.SetDisabled(c.Context(), id, disabled); err != nil {
+			if err.Error() == "user not found" {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update user"})
+		}
+		if !disabled {
+			s.audit(c, models.AuditUserEnabled, id, nil)
+			return c.JSON(fiber.Map{"message": "User enabled"})
+		}
+		if err := s.revokeAllSessions(c.Context(), id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke sessions"})
+		}
+		s.audit(c, models.AuditUserDisabled, id, nil)
+		return c.JSON(fiber.Map{"message": "User disabled"})
+	}
+}
+
+// forcePasswordReset ends all sessions of the user, blocks password logins
+// and mails a reset link.
+func (s *FiberServer) forcePasswordReset(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewUserRepository(s.db.DB())
+	user, err := repo.GetByID(c.Context(), id)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to force password reset"})
+	}
+	if err := repo.RequirePasswordReset(c.Context(), id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to force password reset"})
+	}
+	s.audit(c, models.AuditPasswordResetForced, id, nil)
+	if err := s.revokeAllSessions(c.Context(), id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke sessions"})
+	}
+	if err := s.sendPasswordResetEmail(c.Context(), user.Email); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to send password reset email"})
+	}
+	return c.JSON(fiber.Map{"message": "Password reset required, a reset link has been sent to the user"})
+}
+
+func (s *FiberServer) setUserRole(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var req dto.RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if req.Role != models.RoleUser && req.Role != models.RoleAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "role must be user or admin"})
+	}
+	repo := repositories.NewUserRepository(s.db.DB())
+	if err := repo.SetRole(c.Context(), id, req.Role); err != nil {
+		if err.Error() == "user not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update user"})
+	}
+	s.audit(c, models.AuditRoleChanged, id, fiber.Map{"role": req.Role})
+	return c.JSON(fiber.Map{"message": "Role updated"})
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rytr/internal/database/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireRole(t *testing.T) {
+	for _, tt := range []struct {
+		role   string
+		status int
+	}{
+		{models.RoleUser, http.StatusForbidden},
+		{models.RoleAdmin, http.StatusOK},
+	} {
+		t.Run(tt.role, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals(userLocalsKey, &models.User{Role: tt.role})
+				return c.Next()
+			})
+			app.Get("/admin/users", requireRole(models.RoleAdmin), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/users", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	s := newTestServer(t)
+	_, userToken, _ := registerAndLogin(t, s)
+	adminEmail, adminToken, _ := registerAndLogin(t, s)
+	if _, err := s.db.DB().ExecContext(context.Background(), "UPDATE users SET role = $1 WHERE email = $2", models.RoleAdmin, adminEmail); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct{ method, path string }{
+		{http.MethodGet, "/admin/users"},
+		{http.MethodGet, "/admin/audit-events"},
+		{http.MethodGet, "/admin/memory"},
+		{http.MethodPost, "/admin/users/00000000-0000-0000-0000-000000000000/disable"},
+		{http.MethodPut, "/admin/users/00000000-0000-0000-0000-000000000000/role"},
+	}
+	for _, r := range requests {
+		if status, body := call(t, s, r.method, r.path, userToken, fiber.Map{"role": models.RoleAdmin}); status != http.StatusForbidden {
+			t.Errorf("%s %s as user: status %d, body %v, want 403", r.method, r.path, status, body)
+		}
+	}
+	if status, body := call(t, s, http.MethodGet, "/admin/users", adminToken, nil); status != http.StatusOK {
+		t.Errorf("GET /admin/users as admin: status %d, body %v, want 200", status, body)
+	}
+}
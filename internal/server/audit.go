@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	auditEventsDefaultLimit = 50
+	auditEventsMaxLimit     = 200
+)
+
+// audit records an event of the account userID, which is uuid.Nil when the
+// account is unknown. The authenticated caller is recorded as the actor
+// when it is someone else. Failures are logged and never fail the request.
+// The metadata also records the ID and email of the account.
+func (s *FiberServer) audit(c *fiber.Ctx, eventType string, userID uuid.UUID, metadata fiber.Map) {
+	event := &models.AuditEvent{
+		Type:      eventType,
+		IP:        c.IP(),
+		UserAgent: userAgent(c),
+	}
+	actor, _ := c.Locals(userLocalsKey).(*models.User)
+	if userID != uuid.Nil {
+		event.UserID = &userID
+		metadata = s.auditSubject(c, userID, actor, metadata)
+	}
+	if actor != nil && actor.ID != userID {
+		event.ActorID = &actor.ID
+	}
+	if metadata != nil {
+		raw, err := json.Marshal(metadata)
+		if err != nil {
+			log.Printf("failed to encode audit event %s: %v", eventType, err)
+		}
+		event.Metadata = raw
+	}
+	repo := repositories.NewAuditEventRepository(s.db.DB())
+	if err := repo.Create(c.Context(), event); err != nil {
+		log.Printf("failed to record audit event %s: %v", eventType, err)
+	}
+}
+
+// auditSubject adds the ID and email of the account to the metadata of its
+// event. user_id is cleared when the account is deleted, and the emails are
+// removed when it is purged, leaving the ID to tie its events together.
+func (s *FiberServer) auditSubject(c *fiber.Ctx, userID uuid.UUID, actor *models.User, metadata fiber.Map) fiber.Map {
+	subject := fiber.Map{"user_id": userID}
+	if actor != nil && actor.ID == userID {
+		subject["email"] = actor.Email
+	} else if user, err := repositories.NewUserRepository(s.db.DB()).GetByID(c.Context(), userID); err == nil {
+		subject["email"] = user.Email
+	}
+	for k, v := range metadata {
+		subject[k] = v
+	}
+	return subject
+}
+
+// getSecurityLog lists the events of the authenticated user's account.
+func (s *FiberServer) getSecurityLog(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	limit, offset := pageParams(c, auditEventsDefaultLimit, auditEventsMaxLimit)
+	filter := repositories.AuditEventFilter{UserID: currentUser.ID, Type: c.Query("type")}
+	repo := repositories.NewAuditEventRepository(s.db.DB())
+	events, total, err := repo.Search(c.Context(), filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch security log"})
+	}
+	return c.JSON(fiber.Map{"events": events, "total": total, "limit": limit, "offset": offset})
+}
+
+// getAdminAuditEvents searches the events of all accounts. It accepts the
+// filters user_id, actor_id, type, ip and an RFC 3339 from/to range.
+func (s *FiberServer) getAdminAuditEvents(c *fiber.Ctx) error {
+	limit, offset := pageParams(c, auditEventsDefaultLimit, auditEventsMaxLimit)
+	filter := repositories.AuditEventFilter{Type: c.Query("type"), IP: c.Query("ip")}
+	var err error
+	if v := c.Query("user_id"); v != "" {
+		if filter.UserID, err = uuid.Parse(v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid user_id"})
+		}
+	}
+	if v := c.Query("actor_id"); v != "" {
+		if filter.ActorID, err = uuid.Parse(v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid actor_id"})
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "from must be an RFC 3339 timestamp"})
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "to must be an RFC 3339 timestamp"})
+		}
+	}
+	repo := repositories.NewAuditEventRepository(s.db.DB())
+	events, total, err := repo.Search(c.Context(), filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch audit events"})
+	}
+	return c.JSON(fiber.Map{"events": events, "total": total, "limit": limit, "offset": offset})
+}
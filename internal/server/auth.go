@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Access tokens are short-lived; long-lived sessions are kept alive with
+// rotating refresh tokens which can be revoked server-side.
+var (
+	accessTokenTTL  = utils.GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+	refreshTokenTTL = utils.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+)
+
+// Token types carried in the "typ" claim. Only access tokens are accepted by
+// requireAuth.
+const (
+	tokenTypeAccess       = "access"
+	tokenTypeMFAChallenge = "mfa_challenge"
+)
+
+var mfaChallengeTTL = 5 * time.Minute
+
+var (
+	errInvalidChallenge = errors.New("invalid challenge token")
+	errAccountDisabled  = errors.New("account disabled")
+)
+
+func (s *FiberServer) signAccessToken(user *models.User, sessionID uuid.UUID) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		Type:      tokenTypeAccess,
+		SessionID: sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		},
+	}
+	return s.keys.Sign(claims)
+}
+
+// signChallengeToken returns the token a user with two-factor authentication
+// exchanges, together with a second factor, for real tokens.
+func (s *FiberServer) signChallengeToken(user *models.User) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		Type: tokenTypeMFAChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+		},
+	}
+	return s.keys.Sign(claims)
+}
+
+func (s *FiberServer) parseChallengeToken(raw string) (uuid.UUID, error) {
+	claims := tokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, &claims, s.keys.Keyfunc, jwt.WithValidMethods(s.keys.Methods()), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != tokenTypeMFAChallenge {
+		return uuid.Nil, errInvalidChallenge
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errInvalidChallenge
+	}
+	return id, nil
+}
+
+// completeLogin finishes a successful login once every factor was checked.
+func (s *FiberServer) completeLogin(c *fiber.Ctx, user *models.User, method string) error {
+	tokens, err := s.startSession(c, user, method)
+	if err != nil {
+		if errors.Is(err, errAccountDisabled) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Account disabled"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to log in"})
+	}
+	return c.JSON(tokens)
+}
+
+// startSession issues the tokens of a new login after the user was fully
+// authenticated, by password or by an identity provider. method is recorded
+// in the audit log.
+func (s *FiberServer) startSession(c *fiber.Ctx, user *models.User, method string) (fiber.Map, error) {
+	if user.DisabledAt != nil {
+		return nil, errAccountDisabled
+	}
+	s.resetLoginFailures(c.Context(), user.Email)
+	// Logging back in during the grace period keeps the account.
+	if user.DeletionScheduledAt != nil {
+		repo := repositories.NewUserRepository(s.db.DB())
+		if err := repo.CancelDeletion(c.Context(), user.ID); err != nil {
+			return nil, err
+		}
+	}
+	session, err := s.createSession(c, user)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(c, models.AuditLogin, user.ID, fiber.Map{"method": method, "session_id": session.ID})
+	return s.issueTokens(c.Context(), user, session.ID)
+}
+
+// issueRefreshToken creates a new refresh token in the given family and
+// returns its plaintext value. The plaintext is never stored.
+func (s *FiberServer) issueRefreshToken(ctx context.Context, userID uuid.UUID, familyID uuid.UUID) (string, *models.RefreshToken, error) {
+	plain, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	token := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(plain),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	repo := repositories.NewRefreshTokenRepository(s.db.DB())
+	if err := repo.Create(ctx, token); err != nil {
+		return "", nil, err
+	}
+	return plain, token, nil
+}
+
+// issueTokens creates an access token and a refresh token for a session. The
+// session ID doubles as the family of its refresh tokens.
+func (s *FiberServer) issueTokens(ctx context.Context, user *models.User, familyID uuid.UUID) (fiber.Map, error) {
+	accessToken, err := s.signAccessToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, _, err := s.issueRefreshToken(ctx, user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (s *FiberServer) refreshToken(c *fiber.Ctx) error {
+	var req dto.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "refresh_token is required"})
+	}
+	repo := repositories.NewRefreshTokenRepository(s.db.DB())
+	current, err := repo.GetByHash(c.Context(), utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid refresh token"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to refresh token"})
+	}
+	// A revoked token being presented again means it was stolen or leaked:
+	// kill the whole family so neither party can keep using it.
+	if current.RevokedAt != nil {
+		if err := s.endSession(c.Context(), current); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to refresh token"})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Refresh token reuse detected, please log in again"})
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Refresh token expired"})
+	}
+
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	user, err := userRepo.GetByID(c.Context(), current.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid refresh token"})
+	}
+	if user.DisabledAt != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Account disabled"})
+	}
+
+	accessToken, err := s.signAccessToken(user, current.FamilyID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	plain, next, err := s.issueRefreshToken(c.Context(), user.ID, current.FamilyID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	rotated, err := repo.Rotate(c.Context(), current.ID, next.ID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	// Lost a race against another request presenting the same token.
+	if !rotated {
+		if err := s.endSession(c.Context(), current); err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Refresh token reuse detected, please log in again"})
+	}
+	sessionRepo := repositories.NewSessionRepository(s.db.DB())
+	if err := sessionRepo.Extend(c.Context(), current.FamilyID, next.ExpiresAt, c.IP(), userAgent(c)); err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(fiber.Map{
+		"token":         accessToken,
+		"refresh_token": plain,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+// logout revokes the session the given refresh token belongs to.
+func (s *FiberServer) logout(c *fiber.Ctx) error {
+	var req dto.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "refresh_token is required"})
+	}
+	repo := repositories.NewRefreshTokenRepository(s.db.DB())
+	token, err := repo.GetByHash(c.Context(), utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return c.JSON(fiber.Map{"message": "Logged out"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to log out"})
+	}
+	if err := s.endSession(c.Context(), token); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to log out"})
+	}
+	s.audit(c, models.AuditLogout, token.UserID, fiber.Map{"session_id": token.FamilyID})
+	return c.JSON(fiber.Map{"message": "Logged out"})
+}
+
+// logoutAll revokes every session of the authenticated user.
+func (s *FiberServer) logoutAll(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	if err := s.revokeAllSessions(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to log out"})
+	}
+	s.audit(c, models.AuditLogoutAll, currentUser.ID, nil)
+	return c.JSON(fiber.Map{"message": "Logged out from all devices"})
+}
+
+// getJWKS publishes the public keys so other services can verify tokens.
+func (s *FiberServer) getJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(s.keys.JWKS())
+}
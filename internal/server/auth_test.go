@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newTestServer(t)
+	_, access, first := registerAndLogin(t, s)
+
+	status, body := call(t, s, http.MethodPost, "/token/refresh", "", fiber.Map{"refresh_token": first})
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %v", status, body)
+	}
+	second, _ := body["refresh_token"].(string)
+	if second == "" || second == first {
+		t.Fatalf("refresh: expected a new refresh token, got %v", body)
+	}
+
+	status, body = call(t, s, http.MethodPost, "/token/refresh", "", fiber.Map{"refresh_token": first})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("reusing a rotated token: status %d, body %v, want 401", status, body)
+	}
+	if status, body = call(t, s, http.MethodPost, "/token/refresh", "", fiber.Map{"refresh_token": second}); status != http.StatusUnauthorized {
+		t.Errorf("refreshing after reuse: status %d, body %v, want 401", status, body)
+	}
+	if status, body = call(t, s, http.MethodGet, "/profile", access, nil); status != http.StatusUnauthorized {
+		t.Errorf("access token after reuse: status %d, body %v, want 401", status, body)
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"rytr/internal/avatar"
+	"rytr/internal/blobstore"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const avatarPrefix = "avatars/"
+
+var avatarMaxBytes = int64(utils.GetEnvInt("AVATAR_MAX_BYTES", 2<<20))
+
+// avatarBlobKey returns the key of the rendering of an avatar at size.
+func avatarBlobKey(key string, size int) string {
+	return fmt.Sprintf("%s-%d.png", key, size)
+}
+
+// avatarURLs returns the URL of every size of the user's avatar, or nil if
+// there is none.
+func (s *FiberServer) avatarURLs(user *models.User) fiber.Map {
+	if user.AvatarKey == "" {
+		return nil
+	}
+	urls := fiber.Map{}
+	for _, size := range avatar.Sizes {
+		urls[strconv.Itoa(size)] = s.blobs.URL(avatarBlobKey(user.AvatarKey, size))
+	}
+	return urls
+}
+
+// avatarURL returns the URL of the largest size of the user's avatar.
+func (s *FiberServer) avatarURL(user *models.User) *string {
+	if user.AvatarKey == "" {
+		return nil
+	}
+	url := s.blobs.URL(avatarBlobKey(user.AvatarKey, avatar.Sizes[len(avatar.Sizes)-1]))
+	return &url
+}
+
+// uploadAvatar replaces the avatar with the picture in the "avatar" field of
+// a multipart form. Every avatar gets a new key, so its URLs can be cached
+// forever.
+func (s *FiberServer) uploadAvatar(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "avatar is required"})
+	}
+	if file.Size > avatarMaxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": fmt.Sprintf("Avatar must be at most %d bytes", avatarMaxBytes)})
+	}
+	f, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "avatar is required"})
+	}
+	data, err := io.ReadAll(io.LimitReader(f, avatarMaxBytes+1))
+	f.Close()
+	if err != nil || int64(len(data)) > avatarMaxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": fmt.Sprintf("Avatar must be at most %d bytes", avatarMaxBytes)})
+	}
+
+	// The declared type must match the content, so a mislabeled file is
+	// refused instead of being guessed at.
+	contentType, err := avatar.DetectType(data)
+	declared, _, _ := mime.ParseMediaType(file.Header.Get(fiber.HeaderContentType))
+	if err != nil || (declared != "" && declared != "application/octet-stream" && declared != contentType) {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"message": "Avatar must be a PNG, JPEG or WebP image"})
+	}
+	avatars, err := avatar.Process(data)
+	if errors.Is(err, avatar.ErrTooLarge) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": fmt.Sprintf("Avatar must be at most %dx%d pixels", avatar.MaxDimension, avatar.MaxDimension)})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Avatar could not be read"})
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to save avatar"})
+	}
+	key := avatarPrefix + currentUser.ID.String() + "/" + hex.EncodeToString(suffix)
+	for size, png := range avatars {
+		if err := s.blobs.Put(c.Context(), avatarBlobKey(key, size), png, avatar.ContentType); err != nil {
+			log.Printf("failed to store avatar of user %s: %v", currentUser.ID, err)
+			s.deleteAvatarBlobs(c.Context(), key)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to save avatar"})
+		}
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.SetAvatar(c.Context(), currentUser.ID, key); err != nil {
+		s.deleteAvatarBlobs(c.Context(), key)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to save avatar"})
+	}
+	s.deleteAvatarBlobs(c.Context(), currentUser.AvatarKey)
+	currentUser.AvatarKey = key
+	s.audit(c, models.AuditAvatarUpdated, currentUser.ID, nil)
+	return c.JSON(fiber.Map{"message": "Avatar updated successfully", "avatar_url": s.avatarURL(currentUser), "avatar_urls": s.avatarURLs(currentUser)})
+}
+
+func (s *FiberServer) deleteAvatar(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	if currentUser.AvatarKey == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "No avatar to delete"})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.SetAvatar(c.Context(), currentUser.ID, ""); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete avatar"})
+	}
+	s.deleteAvatarBlobs(c.Context(), currentUser.AvatarKey)
+	s.audit(c, models.AuditAvatarDeleted, currentUser.ID, nil)
+	return c.JSON(fiber.Map{"message": "Avatar deleted successfully"})
+}
+
+// deleteAvatarBlobs removes every size of an avatar. Failures only leave
+// unused blobs behind, so they are logged.
+func (s *FiberServer) deleteAvatarBlobs(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	for _, size := range avatar.Sizes {
+		if err := s.blobs.Delete(ctx, avatarBlobKey(key, size)); err != nil {
+			log.Printf("failed to delete avatar %s: %v", avatarBlobKey(key, size), err)
+		}
+	}
+}
+
+// serveBlob serves avatars from stores that are not public themselves.
+func (s *FiberServer) serveBlob(c *fiber.Ctx) error {
+	key := c.Params("*")
+	if !strings.HasPrefix(key, avatarPrefix) || !blobstore.ValidKey(key) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Not found"})
+	}
+	r, err := s.blobs.Open(c.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to read file"})
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to read file"})
+	}
+	c.Type(strings.TrimPrefix(path.Ext(key), "."))
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	return c.Send(data)
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/export"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// errBoardArchived is returned when adding cards to an archived board.
+var errBoardArchived = errors.New("board is archived")
+
+type boardRequest struct {
+	Name *string `json:"name"`
+}
+
+func (req *boardRequest) apply(board *models.Board) []fieldError {
+	switch {
+	case req.Name == nil || *req.Name == "":
+		return []fieldError{{Field: "name", Code: "required", Message: "Name is required"}}
+	case utf8.RuneCountInString(*req.Name) > export.MaxBoardNameLength:
+		return []fieldError{{Field: "name", Code: "too_long", Message: fmt.Sprintf("Name must be at most %d characters", export.MaxBoardNameLength)}}
+	}
+	board.Name = *req.Name
+	return nil
+}
+
+// boardQuery parses the optional board_id query parameter.
+func boardQuery(c *fiber.Ctx) (*uuid.UUID, error) {
+	raw := c.Query("board_id")
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// resolveBoard returns the board with the given ID, or the default board
+// of the user if id is nil.
+func (s *FiberServer) resolveBoard(ctx context.Context, userID uuid.UUID, id *uuid.UUID) (*models.Board, error) {
+	repo := repositories.NewBoardRepository(s.db.DB())
+	if id != nil {
+		return repo.GetByID(ctx, *id, userID)
+	}
+	if err := repo.EnsureDefault(ctx, userID); err != nil {
+		return nil, err
+	}
+	return repo.GetDefault(ctx, userID)
+}
+
+// writableColumn returns a column cards can be put in, which is any column
+// of the user on a board that is not archived.
+func (s *FiberServer) writableColumn(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*models.BoardColumn, error) {
+	column, err := repositories.NewBoardColumnRepository(s.db.DB()).GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	board, err := repositories.NewBoardRepository(s.db.DB()).GetByID(ctx, column.BoardID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if board.ArchivedAt != nil {
+		return nil, errBoardArchived
+	}
+	return column, nil
+}
+
+// columnError answers for an error of writableColumn.
+func columnError(c *fiber.Ctx, err error, msg string) error {
+	switch {
+	case errors.Is(err, repositories.ErrColumnNotFound), errors.Is(err, repositories.ErrBoardNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Column not found"})
+	case errors.Is(err, errBoardArchived):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Cards cannot be added to an archived board"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": msg})
+}
+
+// getBoards lists the boards of the user. Archived boards are only listed
+// with archived=true.
+func (s *FiberServer) getBoards(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewBoardRepository(s.db.DB())
+	if err := repo.EnsureDefault(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch boards"})
+	}
+	boards, err := repo.GetAll(c.Context(), currentUser.ID, c.QueryBool("archived"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch boards"})
+	}
+	return c.JSON(fiber.Map{"boards": boards})
+}
+
+// createBoard creates a board with the default columns.
+func (s *FiberServer) createBoard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req boardRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	board := models.Board{UserID: currentUser.ID}
+	if errs := req.apply(&board); len(errs) > 0 {
+		return validationFailed(c, "Invalid board", errs)
+	}
+	repo := repositories.NewBoardRepository(s.db.DB())
+	// A user's first board would otherwise keep the default one from ever
+	// being created.
+	if err := repo.EnsureDefault(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create board"})
+	}
+	if err := repo.Create(c.Context(), &board, models.DefaultBoardColumns()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create board"})
+	}
+	columns, err := repositories.NewBoardColumnRepository(s.db.DB()).GetAll(c.Context(), board.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create board"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Board created successfully", "board": board, "columns": columns})
+}
+
+// getBoard returns a board with its columns.
+func (s *FiberServer) getBoard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	board, err := repositories.NewBoardRepository(s.db.DB()).GetByID(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch board"})
+	}
+	columns, err := repositories.NewBoardColumnRepository(s.db.DB()).GetAll(c.Context(), board.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch board"})
+	}
+	return c.JSON(fiber.Map{"board": board, "columns": columns})
+}
+
+func (s *FiberServer) updateBoard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var req boardRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	repo := repositories.NewBoardRepository(s.db.DB())
+	board, err := repo.GetByID(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update board"})
+	}
+	if errs := req.apply(board); len(errs) > 0 {
+		return validationFailed(c, "Invalid board", errs)
+	}
+	if err := repo.Rename(c.Context(), board); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update board"})
+	}
+	return c.JSON(fiber.Map{"message": "Board updated successfully", "board": board})
+}
+
+func (s *FiberServer) archiveBoard(c *fiber.Ctx) error {
+	return s.setBoardArchived(c, true)
+}
+
+func (s *FiberServer) unarchiveBoard(c *fiber.Ctx) error {
+	return s.setBoardArchived(c, false)
+}
+
+// setBoardArchived archives or restores a board. Archived boards keep
+// their cards but drop out of the board and card lists. The last board
+// that is not archived cannot be archived.
+func (s *FiberServer) setBoardArchived(c *fiber.Ctx, archived bool) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewBoardRepository(s.db.DB())
+	if archived {
+		boards, err := repo.GetAll(c.Context(), currentUser.ID, false)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update board"})
+		}
+		if len(*boards) == 1 && (*boards)[0].ID == id {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "The last board cannot be archived"})
+		}
+	}
+	err = repo.SetArchived(c.Context(), id, currentUser.ID, archived)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update board"})
+	}
+	board, err := repo.GetByID(c.Context(), id, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update board"})
+	}
+	return c.JSON(fiber.Map{"message": "Board updated successfully", "board": board})
+}
+
+// deleteBoard deletes a board together with its columns and cards.
+func (s *FiberServer) deleteBoard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewBoardRepository(s.db.DB())
+	// Like archiving, deleting must leave a board new cards can go to.
+	boards, err := repo.GetAll(c.Context(), currentUser.ID, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete board"})
+	}
+	if len(*boards) == 1 && (*boards)[0].ID == id {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "The last board cannot be deleted"})
+	}
+	err = repo.Delete(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete board"})
+	}
+	return c.JSON(fiber.Map{"message": "Board deleted successfully"})
+}
+
+// moveCardToBoard moves a card to another board, into column_id if given,
+// else into the column with the name of its current column, else into the
+// first column.
+func (s *FiberServer) moveCardToBoard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var req struct {
+		BoardID  uuid.UUID  `json:"board_id"`
+		ColumnID *uuid.UUID `json:"column_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	cardRepo := repositories.NewCardRepository(s.db.DB())
+	card, err := cardRepo.GetByID(c.Context(), id, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Card not found"})
+	}
+	board, err := repositories.NewBoardRepository(s.db.DB()).GetByID(c.Context(), req.BoardID, currentUser.ID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	if board.ArchivedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Cards cannot be added to an archived board"})
+	}
+	columnRepo := repositories.NewBoardColumnRepository(s.db.DB())
+	columns, err := columnRepo.GetAll(c.Context(), board.ID, currentUser.ID)
+	if err != nil || len(*columns) == 0 {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	target := (*columns)[0].ID
+	if req.ColumnID != nil {
+		if !slices.ContainsFunc(*columns, func(column models.BoardColumn) bool { return column.ID == *req.ColumnID }) {
+			return validationFailed(c, "Invalid move", []fieldError{{Field: "column_id", Code: "invalid", Message: "Column must be on the board"}})
+		}
+		target = *req.ColumnID
+	} else if current, err := columnRepo.GetByID(c.Context(), card.ColumnID, currentUser.ID); err == nil {
+		for _, column := range *columns {
+			if strings.EqualFold(column.Name, current.Name) {
+				target = column.ID
+				break
+			}
+		}
+	}
+	if err := cardRepo.MoveToColumn(c.Context(), card.ID, target, currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	card, err = cardRepo.GetByID(c.Context(), card.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	return c.JSON(fiber.Map{"message": "Card moved successfully", "card": card})
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/export"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var columnColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type columnRequest struct {
+	BoardID *uuid.UUID `json:"board_id"`
+	Name    *string    `json:"name"`
+	Color   *string    `json:"color"`
+	Done    *bool      `json:"done"`
+}
+
+// apply copies the fields present in the request to column and reports
+// those that are invalid.
+func (req *columnRequest) apply(column *models.BoardColumn) []fieldError {
+	var errs []fieldError
+	if req.Name != nil {
+		switch {
+		case *req.Name == "":
+			errs = append(errs, fieldError{Field: "name", Code: "required", Message: "Name is required"})
+		case utf8.RuneCountInString(*req.Name) > export.MaxColumnNameLength:
+			errs = append(errs, fieldError{Field: "name", Code: "too_long", Message: fmt.Sprintf("Name must be at most %d characters", export.MaxColumnNameLength)})
+		default:
+			column.Name = *req.Name
+		}
+	}
+	if req.Color != nil {
+		if *req.Color != "" && !columnColor.MatchString(*req.Color) {
+			errs = append(errs, fieldError{Field: "color", Code: "invalid", Message: "Color must look like #1a2b3c"})
+		} else {
+			column.Color = *req.Color
+		}
+	}
+	if req.Done != nil {
+		column.Done = *req.Done
+	}
+	return errs
+}
+
+// defaultColumn returns the column new cards of the user go to: the one
+// chosen in the settings, or else the first one of the default board.
+func (s *FiberServer) defaultColumn(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	repo := repositories.NewBoardColumnRepository(s.db.DB())
+	if id := s.userSettings(ctx, userID).DefaultColumnID; id != nil {
+		if column, err := s.writableColumn(ctx, userID, *id); err == nil {
+			return column.ID, nil
+		}
+	}
+	board, err := s.resolveBoard(ctx, userID, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	columns, err := repo.GetAll(ctx, board.ID, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(*columns) == 0 {
+		return uuid.Nil, repositories.ErrColumnNotFound
+	}
+	return (*columns)[0].ID, nil
+}
+
+// getColumns lists the columns of the board given as board_id, or of the
+// default board.
+func (s *FiberServer) getColumns(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	boardID, err := boardQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid board_id"})
+	}
+	board, err := s.resolveBoard(c.Context(), currentUser.ID, boardID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch columns"})
+	}
+	repo := repositories.NewBoardColumnRepository(s.db.DB())
+	columns, err := repo.GetAll(c.Context(), board.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch columns"})
+	}
+	return c.JSON(fiber.Map{"columns": columns})
+}
+
+func (s *FiberServer) createColumn(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req columnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if req.Name == nil {
+		req.Name = new(string)
+	}
+	column := models.BoardColumn{UserID: currentUser.ID}
+	if errs := req.apply(&column); len(errs) > 0 {
+		return validationFailed(c, "Invalid column", errs)
+	}
+	board, err := s.resolveBoard(c.Context(), currentUser.ID, req.BoardID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create column"})
+	}
+	column.BoardID = board.ID
+	repo := repositories.NewBoardColumnRepository(s.db.DB())
+	if err := repo.Create(c.Context(), &column); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create column"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Column created successfully", "column": column})
+}
+
+func (s *FiberServer) updateColumn(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var req columnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	repo := repositories.NewBoardColumnRepository(s.db.DB())
+	column, err := repo.GetByID(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrColumnNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Column not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update column"})
+	}
+	if errs := req.apply(column); len(errs) > 0 {
+		return validationFailed(c, "Invalid column", errs)
+	}
+	if err := repo.Update(c.Context(), column); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update column"})
+	}
+	return c.JSON(fiber.Map{"message": "Column updated successfully", "column": column})
+}
+
+// reorderColumns takes every column of a board in the new order.
+func (s *FiberServer) reorderColumns(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req struct {
+		BoardID   *uuid.UUID  `json:"board_id"`
+		ColumnIDs []uuid.UUID `json:"column_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	board, err := s.resolveBoard(c.Context(), currentUser.ID, req.BoardID)
+	if errors.Is(err, repositories.ErrBoardNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reorder columns"})
+	}
+	repo := repositories.NewBoardColumnRepository(s.db.DB())
+	columns, err := repo.GetAll(c.Context(), board.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reorder columns"})
+	}
+	remaining := map[uuid.UUID]bool{}
+	for _, column := range *columns {
+		remaining[column.ID] = true
+	}
+	for _, id := range req.ColumnIDs {
+		if !remaining[id] {
+			return validationFailed(c, "Invalid order", []fieldError{{Field: "column_ids", Code: "invalid", Message: "Every column must be listed exactly once"}})
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		return validationFailed(c, "Invalid order", []fieldError{{Field: "column_ids", Code: "invalid", Message: "Every column must be listed exactly once"}})
+	}
+	if err := repo.Reorder(c.Context(), board.ID, currentUser.ID, req.ColumnIDs); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reorder columns"})
+	}
+	columns, err = repo.GetAll(c.Context(), board.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reorder columns"})
+	}
+	return c.JSON(fiber.Map{"message": "Columns reordered successfully", "columns": columns})
+}
+
+// deleteColumn deletes a column. Its cards are moved to the column given
+// as move_to, on the same board; without it only empty columns can be
+// deleted.
+func (s *FiberServer) deleteColumn(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewBoardColumnRepository(s.db.DB())
+	column, err := repo.GetByID(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrColumnNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Column not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete column"})
+	}
+	var moveTo *uuid.UUID
+	if raw := c.Query("move_to"); raw != "" {
+		target, err := uuid.Parse(raw)
+		if err != nil || target == id {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "move_to must be another column of the same board"})
+		}
+		if other, err := repo.GetByID(c.Context(), target, currentUser.ID); err != nil || other.BoardID != column.BoardID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "move_to must be another column of the same board"})
+		}
+		moveTo = &target
+	}
+	columns, err := repo.GetAll(c.Context(), column.BoardID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete column"})
+	}
+	if len(*columns) == 1 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "The last column of a board cannot be deleted"})
+	}
+	// The order of the cards is read before they are moved, so both happen
+	// in one transaction.
+	tx, err := s.db.DB().BeginTx(c.Context(), nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete column"})
+	}
+	defer tx.Rollback()
+	err = repositories.NewBoardColumnRepository(tx).Delete(c.Context(), id, currentUser.ID, moveTo)
+	switch {
+	case errors.Is(err, repositories.ErrColumnNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Column not found"})
+	case errors.Is(err, repositories.ErrColumnNotEmpty):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Column has cards, pass move_to to move them"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete column"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete column"})
+	}
+	return c.JSON(fiber.Map{"message": "Column deleted successfully"})
+}
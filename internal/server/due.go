@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/export"
+	"rytr/internal/notify"
+	"rytr/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var (
+	reminderInterval = utils.GetEnvDuration("REMINDER_INTERVAL", time.Minute)
+	// reminderMaxDelay is how late a reminder may still fire, for example
+	// after downtime or when a due date is set to just before now.
+	reminderMaxDelay = utils.GetEnvDuration("REMINDER_MAX_DELAY", time.Hour)
+)
+
+const reminderBatchSize = 100
+
+const dueTimeFormat = "Mon, 02 Jan 2006 15:04 MST"
+
+func validateCardDates(card *models.Card) []fieldError {
+	if card.StartsAt != nil && card.DueAt != nil && card.StartsAt.After(*card.DueAt) {
+		return []fieldError{{Field: "starts_at", Code: "after_due", Message: "Start date must not be after the due date"}}
+	}
+	return nil
+}
+
+// getOverdueCards lists unfinished cards whose due date has passed.
+func (s *FiberServer) getOverdueCards(c *fiber.Ctx) error {
+	now := time.Now()
+	return s.listCards(c, repositories.CardFilter{Pending: true, DueBefore: &now})
+}
+
+// getCardsDueToday lists unfinished cards due today in the time zone of the
+// user, including those already overdue today.
+func (s *FiberServer) getCardsDueToday(c *fiber.Ctx) error {
+	settings := s.userSettings(c.Context(), userFromContext(c).ID)
+	from, before := settings.Today(time.Now())
+	return s.listCards(c, repositories.CardFilter{Pending: true, DueFrom: &from, DueBefore: &before})
+}
+
+// getCardsDueThisWeek lists unfinished cards due this week, which starts on
+// the week_start of the user's settings.
+func (s *FiberServer) getCardsDueThisWeek(c *fiber.Ctx) error {
+	settings := s.userSettings(c.Context(), userFromContext(c).ID)
+	from, before := settings.ThisWeek(time.Now())
+	return s.listCards(c, repositories.CardFilter{Pending: true, DueFrom: &from, DueBefore: &before})
+}
+
+func (s *FiberServer) getCardReminders(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	cardID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	if _, err := repositories.NewCardRepository(s.db.DB()).GetByID(c.Context(), cardID, currentUser.ID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Card not found"})
+	}
+	reminders, err := repositories.NewCardReminderRepository(s.db.DB()).GetForCard(c.Context(), cardID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch reminders"})
+	}
+	return c.JSON(fiber.Map{"reminders": reminders})
+}
+
+// createCardReminder adds a reminder minutes_before the due date of a card.
+// Reminders of cards without a due date wait until one is set.
+func (s *FiberServer) createCardReminder(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	cardID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var req struct {
+		MinutesBefore *int `json:"minutes_before"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	switch {
+	case req.MinutesBefore == nil:
+		return validationFailed(c, "Invalid reminder", []fieldError{{Field: "minutes_before", Code: "required", Message: "Minutes before the due date are required"}})
+	case *req.MinutesBefore < 0 || *req.MinutesBefore > export.MaxReminderMinutes:
+		return validationFailed(c, "Invalid reminder", []fieldError{{Field: "minutes_before", Code: "out_of_range", Message: fmt.Sprintf("Minutes before the due date must be between 0 and %d", export.MaxReminderMinutes)}})
+	}
+	reminder := models.CardReminder{CardID: cardID, UserID: currentUser.ID, MinutesBefore: *req.MinutesBefore}
+	err = repositories.NewCardReminderRepository(s.db.DB()).Create(c.Context(), &reminder)
+	switch {
+	case errors.Is(err, repositories.ErrReminderNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Card not found"})
+	case errors.Is(err, repositories.ErrReminderExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "The card already has this reminder"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create reminder"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Reminder created successfully", "reminder": reminder})
+}
+
+func (s *FiberServer) deleteCardReminder(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	cardID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	id, err := uuid.Parse(c.Params("reminderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	err = repositories.NewCardReminderRepository(s.db.DB()).Delete(c.Context(), id, cardID, currentUser.ID)
+	if errors.Is(err, repositories.ErrReminderNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Reminder not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete reminder"})
+	}
+	return c.JSON(fiber.Map{"message": "Reminder deleted successfully"})
+}
+
+// sendDueReminders notifies users of the reminders whose time has come. A
+// reminder is marked as sent before the notification goes out, so it is
+// never sent twice, even by several API processes.
+func (s *FiberServer) sendDueReminders(ctx context.Context) error {
+	repo := repositories.NewCardReminderRepository(s.db.DB())
+	for {
+		now := time.Now()
+		reminders, err := repo.Due(ctx, now.Add(-reminderMaxDelay), now, reminderBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, reminder := range *reminders {
+			claimed, err := repo.MarkSent(ctx, reminder.ID, reminder.DueAt)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			if err := s.notifier.Notify(ctx, s.reminderNotification(ctx, reminder)); err != nil {
+				log.Printf("failed to send reminder %s: %v", reminder.ID, err)
+			}
+		}
+		if len(*reminders) < reminderBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *FiberServer) reminderNotification(ctx context.Context, reminder models.DueReminder) notify.Notification {
+	due := reminder.DueAt.In(s.userSettings(ctx, reminder.UserID).Location()).Format(dueTimeFormat)
+	return notify.Notification{
+		Kind:   notify.KindCardReminder,
+		UserID: reminder.UserID,
+		Email:  reminder.Email,
+		Title:  fmt.Sprintf("Reminder: %s is due %s", reminder.CardTitle, due),
+		Body:   fmt.Sprintf("Hi %s,\n\nyour card %q is due %s.", reminder.FirstName, reminder.CardTitle, due),
+		Link:   fmt.Sprintf("%s/cards/%s", appBaseURL, reminder.CardID),
+		Data: map[string]any{
+			"card_id":        reminder.CardID,
+			"reminder_id":    reminder.ID,
+			"due_at":         reminder.DueAt,
+			"minutes_before": reminder.MinutesBefore,
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/mailer"
+	"rytr/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Email verification modes selected with EMAIL_VERIFICATION.
+const (
+	verificationOff    = "off"
+	verificationLogin  = "login"
+	verificationWrites = "writes"
+)
+
+var (
+	// appBaseURL is the client application links in emails point to.
+	appBaseURL            = strings.TrimSuffix(utils.GetEnv("APP_BASE_URL", "http://localhost:5173"), "/")
+	emailVerificationMode = strings.ToLower(utils.GetEnv("EMAIL_VERIFICATION", verificationOff))
+	emailVerificationTTL  = utils.GetEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour)
+)
+
+// createUserToken stores a new single-use token and returns its plaintext.
+func (s *FiberServer) createUserToken(ctx context.Context, userID uuid.UUID, purpose string, payload string, ttl time.Duration) (string, error) {
+	plain, err := utils.NewSignedToken(purpose)
+	if err != nil {
+		return "", err
+	}
+	repo := repositories.NewUserTokenRepository(s.db.DB())
+	err = repo.Create(ctx, &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(plain),
+		Payload:   payload,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// consumeUserToken validates and burns a token created by createUserToken.
+func (s *FiberServer) consumeUserToken(ctx context.Context, purpose string, plain string) (*models.UserToken, error) {
+	if !utils.VerifySignedToken(purpose, plain) {
+		return nil, repositories.ErrUserTokenInvalid
+	}
+	repo := repositories.NewUserTokenRepository(s.db.DB())
+	return repo.Consume(ctx, purpose, utils.HashToken(plain))
+}
+
+// sendMail delivers msg in the background so slow mail servers neither delay
+// responses nor leak timing information.
+func (s *FiberServer) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("failed to send %q mail: %v", msg.Subject, err)
+		}
+	}()
+}
+
+func appLink(path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", appBaseURL, path, url.QueryEscape(token))
+}
+
+func (s *FiberServer) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	repo := repositories.NewUserTokenRepository(s.db.DB())
+	if err := repo.DeleteForUser(ctx, user.ID, models.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+	token, err := s.createUserToken(ctx, user.ID, models.TokenPurposeEmailVerification, "", emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, appLink("/verify-email", token), emailVerificationTTL),
+	})
+	return nil
+}
+
+func (s *FiberServer) verifyEmail(c *fiber.Ctx) error {
+	var req dto.TokenRequest
+	req.Token = c.Query("token")
+	if req.Token == "" {
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "token is required"})
+		}
+	}
+	token, err := s.consumeUserToken(c.Context(), models.TokenPurposeEmailVerification, req.Token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserTokenInvalid) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid or expired verification link"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to verify email"})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.MarkEmailVerified(c.Context(), token.UserID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to verify email"})
+	}
+	return c.JSON(fiber.Map{"message": "Email verified successfully"})
+}
+
+// resendVerificationEmail works in the background and always answers the same
+// way, so neither content nor timing reveals which addresses are registered.
+// It is throttled like forgotPassword.
+func (s *FiberServer) resendVerificationEmail(c *fiber.Ctx) error {
+	var req dto.EmailRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "email is required"})
+	}
+	wait, err := s.mailWait(c.Context(), c.IP(), req.Email)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to resend verification email"})
+	}
+	if wait > 0 {
+		return tooManyRequests(c, wait)
+	}
+	go func(email string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		userRepo := repositories.NewUserRepository(s.db.DB())
+		user, err := userRepo.GetByEmail(ctx, email)
+		if err != nil || user.EmailVerifiedAt != nil {
+			return
+		}
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("failed to resend verification email: %v", err)
+		}
+	}(req.Email)
+	return c.JSON(fiber.Map{"message": "If the address belongs to an unverified account, a verification email has been sent"})
+}
+
+// requireVerifiedEmail rejects state changing requests from unverified users
+// when EMAIL_VERIFICATION is set to "writes". RegisterFiberRoutes installs it
+// right after requireAuth; the few writes unverified users may make are
+// registered before it.
+func (s *FiberServer) requireVerifiedEmail(c *fiber.Ctx) error {
+	if emailVerificationMode != verificationWrites {
+		return c.Next()
+	}
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return c.Next()
+	}
+	currentUser := userFromContext(c)
+	if currentUser.EmailVerifiedAt == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Email address not verified"})
+	}
+	return c.Next()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/mailer"
+	"rytr/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var emailChangeTTL = utils.GetEnvDuration("EMAIL_CHANGE_TTL", 24*time.Hour)
+
+// requestEmailChange mails a confirmation link to the new address and a
+// notice with a cancel link to the current one. The email only changes once
+// the new address is confirmed.
+func (s *FiberServer) requestEmailChange(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+
+	var req dto.ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	if req.NewEmail == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "New email is required"})
+	}
+	if addr, err := mail.ParseAddress(req.NewEmail); err != nil || addr.Address != req.NewEmail {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid email address"})
+	}
+	if strings.EqualFold(req.NewEmail, currentUser.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "New email is the same as the current one"})
+	}
+	if status, message := s.reauthenticate(c, currentUser, req.Password, req.Code, req.RecoveryCode); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"message": message})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if _, err := userRepo.GetByEmail(c.Context(), req.NewEmail); err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Email already in use"})
+	}
+
+	// Only the most recent request stays valid.
+	tokenRepo := repositories.NewUserTokenRepository(s.db.DB())
+	for _, purpose := range []string{models.TokenPurposeEmailChange, models.TokenPurposeEmailChangeCancel} {
+		if err := tokenRepo.DeleteForUser(c.Context(), currentUser.ID, purpose); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to change email"})
+		}
+	}
+	confirmToken, err := s.createUserToken(c.Context(), currentUser.ID, models.TokenPurposeEmailChange, req.NewEmail, emailChangeTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to change email"})
+	}
+	cancelToken, err := s.createUserToken(c.Context(), currentUser.ID, models.TokenPurposeEmailChangeCancel, req.NewEmail, emailChangeTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to change email"})
+	}
+
+	s.audit(c, models.AuditEmailChangeRequested, currentUser.ID, fiber.Map{"new_email": req.NewEmail})
+
+	s.sendMail(mailer.Message{
+		To:      req.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your rytr account by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			currentUser.FirstName, appLink("/confirm-email-change", confirmToken), emailChangeTTL),
+	})
+	s.sendMail(mailer.Message{
+		To:      currentUser.Email,
+		Subject: "Your email address is about to change",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to change the email address of your rytr account to %s. If this was not you, cancel the change and reset your password:\n\n%s\n",
+			currentUser.FirstName, req.NewEmail, appLink("/cancel-email-change", cancelToken)),
+	})
+
+	return c.JSON(fiber.Map{"message": "A confirmation link has been sent to the new address"})
+}
+
+// confirmEmailChange swaps the email, ends every session and deletes every
+// personal access token, so all devices and scripts have to log in again
+// with the new address.
+func (s *FiberServer) confirmEmailChange(c *fiber.Ctx) error {
+	var req dto.TokenRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "token is required"})
+	}
+	token, err := s.consumeUserToken(c.Context(), models.TokenPurposeEmailChange, req.Token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserTokenInvalid) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid or expired confirmation link"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to change email"})
+	}
+
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.ChangeEmail(c.Context(), token.UserID, token.Payload); err != nil {
+		// Someone registered the address after the change was requested.
+		if errors.Is(err, repositories.ErrEmailTaken) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Email already in use"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to change email"})
+	}
+	tokenRepo := repositories.NewUserTokenRepository(s.db.DB())
+	if err := tokenRepo.DeleteForUser(c.Context(), token.UserID, models.TokenPurposeEmailChangeCancel); err != nil {
+		log.Printf("failed to delete email change tokens: %v", err)
+	}
+	if err := s.revokeAllSessions(c.Context(), token.UserID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke sessions and tokens"})
+	}
+	s.audit(c, models.AuditEmailChanged, token.UserID, fiber.Map{"new_email": token.Payload})
+
+	return c.JSON(fiber.Map{"message": "Email changed, please log in again"})
+}
+
+// cancelEmailChange is used from the notice sent to the old address.
+func (s *FiberServer) cancelEmailChange(c *fiber.Ctx) error {
+	var req dto.TokenRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "token is required"})
+	}
+	token, err := s.consumeUserToken(c.Context(), models.TokenPurposeEmailChangeCancel, req.Token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserTokenInvalid) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid or expired link"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to cancel email change"})
+	}
+	tokenRepo := repositories.NewUserTokenRepository(s.db.DB())
+	if err := tokenRepo.DeleteForUser(c.Context(), token.UserID, models.TokenPurposeEmailChange); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to cancel email change"})
+	}
+	s.audit(c, models.AuditEmailChangeCancelled, token.UserID, fiber.Map{"new_email": token.Payload})
+	return c.JSON(fiber.Map{"message": "Email change cancelled. If you did not request it, reset your password"})
+}
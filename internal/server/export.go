@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/export"
+	"rytr/internal/mailer"
+	"rytr/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const exportLinkPurpose = "data_export"
+
+var (
+	exportDir = utils.GetEnv("EXPORT_DIR", "tmp/exports")
+	exportTTL = utils.GetEnvDuration("EXPORT_TTL", 7*24*time.Hour)
+	// apiBaseURL is the public URL of this API, used for download links.
+	apiBaseURL = strings.TrimSuffix(utils.GetEnv("API_BASE_URL", "http://localhost:"+utils.GetEnv("PORT", "8080")), "/")
+)
+
+// exportLink is signed into download URLs so they work without a login but
+// only until the export expires.
+type exportLink struct {
+	ID        string `json:"i"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func exportPath(id uuid.UUID) string {
+	return filepath.Join(exportDir, id.String()+".zip")
+}
+
+func exportDownloadURL(e *models.DataExport) string {
+	value, _ := json.Marshal(exportLink{ID: e.ID.String(), ExpiresAt: e.ExpiresAt.Unix()})
+	return apiBaseURL + "/export/download?token=" + url.QueryEscape(utils.SignValue(exportLinkPurpose, value))
+}
+
+// requestExport starts building an archive of the user's data in the
+// background. The user is mailed a download link once it is ready.
+func (s *FiberServer) requestExport(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	e := &models.DataExport{UserID: currentUser.ID}
+	repo := repositories.NewDataExportRepository(s.db.DB())
+	created, err := repo.Create(c.Context(), e)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to start export"})
+	}
+	if !created {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "An export is already in progress"})
+	}
+	s.audit(c, models.AuditExportRequested, currentUser.ID, fiber.Map{"export_id": e.ID})
+	go func(user models.User, id uuid.UUID) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		if err := s.buildExport(ctx, &user, id); err != nil {
+			log.Printf("failed to build export %s: %v", id, err)
+			// ctx may be what ran out, so the failure gets a context of its own.
+			failCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := repo.MarkFailed(failCtx, id, err.Error()); err != nil {
+				log.Printf("failed to mark export %s as failed: %v", id, err)
+			}
+		}
+	}(*currentUser, e.ID)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Export started, you will receive an email when it is ready", "export": e})
+}
+
+func (s *FiberServer) buildExport(ctx context.Context, user *models.User, id uuid.UUID) error {
+	boardRepo := repositories.NewBoardRepository(s.db.DB())
+	boards, err := boardRepo.GetAll(ctx, user.ID, true)
+	if err != nil {
+		return err
+	}
+	columnRepo := repositories.NewBoardColumnRepository(s.db.DB())
+	columns, err := columnRepo.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	labelRepo := repositories.NewLabelRepository(s.db.DB())
+	labelCounts, err := labelRepo.GetAll(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	labels := make([]models.Label, 0, len(*labelCounts))
+	for _, l := range *labelCounts {
+		labels = append(labels, l.Label)
+	}
+	cardRepo := repositories.NewCardRepository(s.db.DB())
+	cards, err := cardRepo.GetAll(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	reminderRepo := repositories.NewCardReminderRepository(s.db.DB())
+	reminders, err := reminderRepo.GetAll(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	noteRepo := repositories.NewNoteRepository(s.db.DB())
+	notes, err := noteRepo.GetAll(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(exportDir, 0o700); err != nil {
+		return err
+	}
+	// Write to a temporary name so a half written archive is never served.
+	tmp, err := os.CreateTemp(exportDir, id.String()+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	loc := s.userSettings(ctx, user.ID).Location()
+	if err := export.Write(tmp, user, export.NewDocument(*boards, *columns, labels, *cards, *reminders, *notes, time.Now()), loc); err != nil {
+		tmp.Close()
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exportPath(id)); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(exportTTL)
+	repo := repositories.NewDataExportRepository(s.db.DB())
+	if err := repo.MarkReady(ctx, id, info.Size(), expiresAt); err != nil {
+		return err
+	}
+	link := exportDownloadURL(&models.DataExport{ID: id, ExpiresAt: &expiresAt})
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your rytr export is ready",
+		Body: fmt.Sprintf("Hi %s,\n\nThe archive with your profile, cards and notes is ready. Download it here:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, link, exportTTL),
+	})
+	return nil
+}
+
+// getExports lists the user's exports with a download link for those that
+// are ready.
+func (s *FiberServer) getExports(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewDataExportRepository(s.db.DB())
+	exports, err := repo.GetAll(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch exports"})
+	}
+	result := make([]fiber.Map, 0, len(*exports))
+	for i := range *exports {
+		e := &(*exports)[i]
+		item := fiber.Map{
+			"id":           e.ID,
+			"status":       e.Status,
+			"size_bytes":   e.SizeBytes,
+			"created_at":   e.CreatedAt,
+			"completed_at": e.CompletedAt,
+			"expires_at":   e.ExpiresAt,
+		}
+		if e.Status == models.ExportReady && e.ExpiresAt != nil && time.Now().Before(*e.ExpiresAt) {
+			item["download_url"] = exportDownloadURL(e)
+		}
+		result = append(result, item)
+	}
+	return c.JSON(fiber.Map{"exports": result})
+}
+
+// downloadExport serves an archive to whoever holds a valid download link.
+func (s *FiberServer) downloadExport(c *fiber.Ctx) error {
+	var link exportLink
+	value, ok := utils.VerifySignedValue(exportLinkPurpose, c.Query("token"))
+	if !ok || json.Unmarshal(value, &link) != nil || time.Now().Unix() > link.ExpiresAt {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Invalid or expired download link"})
+	}
+	id, err := uuid.Parse(link.ID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Invalid or expired download link"})
+	}
+	repo := repositories.NewDataExportRepository(s.db.DB())
+	e, err := repo.GetByID(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrDataExportNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Invalid or expired download link"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to download export"})
+	}
+	if e.Status != models.ExportReady {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Invalid or expired download link"})
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Download(exportPath(e.ID), "rytr-export-"+e.CreatedAt.UTC().Format("2006-01-02")+".zip")
+}
+
+// pruneExports deletes expired exports and every file in exportDir that
+// outlived them, including archives of deleted accounts.
+func (s *FiberServer) pruneExports(ctx context.Context) error {
+	repo := repositories.NewDataExportRepository(s.db.DB())
+	if _, err := repo.Prune(ctx, time.Now()); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(exportDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || time.Since(info.ModTime()) < exportTTL {
+			continue
+		}
+		if err := os.Remove(filepath.Join(exportDir, entry.Name())); err != nil {
+			log.Printf("failed to remove export %s: %v", entry.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/export"
+	"rytr/internal/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	importModeMerge   = "merge"
+	importModeReplace = "replace"
+)
+
+// importMaxBytes is the largest document accepted by importData, both as
+// upload and after unpacking an archive.
+var importMaxBytes = int64(utils.GetEnvInt("IMPORT_MAX_BYTES", 32<<20))
+
+var errImportTooLarge = errors.New("import too large")
+
+// importResult reports what happened to one card or note of an archive.
+type importResult struct {
+	Type   string    `json:"type"`
+	Index  int       `json:"index"`
+	ID     uuid.UUID `json:"id"`
+	Title  string    `json:"title"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// importData loads the cards and notes of a rytr archive into the account.
+// The archive is either the body itself, as JSON or ZIP, or the "archive"
+// file of a multipart form. In merge mode cards and notes that already
+// exist, by ID or identical content, are skipped; replace mode deletes
+// everything first. Nothing is imported unless every item is.
+func (s *FiberServer) importData(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	mode := c.Query("mode", c.FormValue("mode", importModeMerge))
+	if mode != importModeMerge && mode != importModeReplace {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "mode must be merge or replace"})
+	}
+	data, err := importBody(c)
+	if errors.Is(err, errImportTooLarge) {
+		return importTooLarge(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "archive is required"})
+	}
+	var doc *export.Document
+	if export.IsArchive(data) {
+		doc, err = export.ReadArchive(bytes.NewReader(data), int64(len(data)), importMaxBytes)
+	} else {
+		doc, err = export.ParseDocument(data)
+	}
+	if errors.Is(err, export.ErrTooLarge) {
+		return importTooLarge(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid archive"})
+	}
+	if problems := doc.Validate(); len(problems) > 0 {
+		errs := make([]fieldError, 0, len(problems))
+		for _, p := range problems {
+			errs = append(errs, fieldError{Field: p.Field, Code: p.Code, Message: p.Message})
+		}
+		return validationFailed(c, "Archive cannot be imported", errs)
+	}
+
+	results, err := s.runImport(c.Context(), currentUser.ID, doc, mode)
+	if err != nil {
+		log.Printf("failed to import data for user %s: %v", currentUser.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to import data"})
+	}
+	counts := fiber.Map{}
+	for _, r := range results {
+		key := r.Type + "s_" + r.Status
+		n, _ := counts[key].(int)
+		counts[key] = n + 1
+	}
+	s.audit(c, models.AuditDataImported, currentUser.ID, fiber.Map{"mode": mode, "counts": counts})
+	return c.JSON(fiber.Map{"message": "Import complete", "mode": mode, "counts": counts, "results": results})
+}
+
+func importTooLarge(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": fmt.Sprintf("Archive must be at most %d bytes", importMaxBytes)})
+}
+
+func importBody(c *fiber.Ctx) ([]byte, error) {
+	if file, err := c.FormFile("archive"); err == nil {
+		if file.Size > importMaxBytes {
+			return nil, errImportTooLarge
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, importMaxBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > importMaxBytes {
+			return nil, errImportTooLarge
+		}
+		return data, nil
+	}
+	if len(c.Body()) == 0 {
+		return nil, errors.New("empty body")
+	}
+	if int64(len(c.Body())) > importMaxBytes {
+		return nil, errImportTooLarge
+	}
+	return c.Body(), nil
+}
+
+// runImport inserts the document in a single transaction.
+func (s *FiberServer) runImport(ctx context.Context, userID uuid.UUID, doc *export.Document, mode string) ([]importResult, error) {
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	boardRepo := repositories.NewBoardRepository(tx)
+	columnRepo := repositories.NewBoardColumnRepository(tx)
+	cardRepo := repositories.NewCardRepository(tx)
+	noteRepo := repositories.NewNoteRepository(tx)
+	labelRepo := repositories.NewLabelRepository(tx)
+	reminderRepo := repositories.NewCardReminderRepository(tx)
+
+	// seen holds the IDs and content keys of everything in the account.
+	seen := map[string]bool{}
+	if mode == importModeReplace {
+		if _, err := cardRepo.DeleteAll(ctx, userID); err != nil {
+			return nil, err
+		}
+		if _, err := noteRepo.DeleteAll(ctx, userID); err != nil {
+			return nil, err
+		}
+		if err := boardRepo.DeleteAll(ctx, userID); err != nil {
+			return nil, err
+		}
+		if _, err := labelRepo.DeleteAll(ctx, userID); err != nil {
+			return nil, err
+		}
+	} else {
+		cards, err := cardRepo.GetAll(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, card := range *cards {
+			seen[card.ID.String()] = true
+			seen[cardKey(card.Title, card.Description)] = true
+		}
+		notes, err := noteRepo.GetAll(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, note := range *notes {
+			seen[note.ID.String()] = true
+			seen[noteKey(note.Title, note.Content)] = true
+		}
+	}
+
+	results := make([]importResult, 0, len(doc.Boards)+len(doc.Columns)+len(doc.Labels)+len(doc.Cards)+len(doc.Notes))
+	boardIDs, results, err := importBoards(ctx, boardRepo, userID, doc.Boards, results)
+	if err != nil {
+		return nil, err
+	}
+	columns, results, err := importColumns(ctx, columnRepo, userID, doc.Columns, boardIDs, results)
+	if err != nil {
+		return nil, err
+	}
+	labelIDs, results, err := importLabels(ctx, labelRepo, userID, doc.Labels, results)
+	if err != nil {
+		return nil, err
+	}
+	// relabel puts the labels of an archived card or note on the item it
+	// was imported as.
+	relabel := func(target repositories.LabelTarget, id uuid.UUID, archived []uuid.UUID) error {
+		if len(archived) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, 0, len(archived))
+		for _, labelID := range archived {
+			ids = append(ids, labelIDs[labelID])
+		}
+		return labelRepo.Set(ctx, target, id, ids, userID)
+	}
+
+	now := time.Now()
+	for i, c := range doc.Cards {
+		result := importResult{Type: "card", Index: i, ID: c.ID, Title: c.Title}
+		key := cardKey(c.Title, c.Description)
+		if reason := duplicateReason(seen, c.ID, key); reason != "" {
+			result.Status, result.Reason = "skipped", reason
+			results = append(results, result)
+			continue
+		}
+		card := &models.Card{
+			ID:          c.ID,
+			Title:       c.Title,
+			Description: c.Description,
+			ColumnID:    columns[c.ColumnID].ID,
+			BoardID:     columns[c.ColumnID].BoardID,
+			StartsAt:    c.StartsAt,
+			DueAt:       c.DueAt,
+			CreatedAt:   orNow(c.CreatedAt, now),
+			UpdatedAt:   orNow(c.UpdatedAt, now),
+			UserID:      userID,
+		}
+		if err := importWithID(&card.ID, func() (bool, error) { return cardRepo.Import(ctx, card) }); err != nil {
+			return nil, err
+		}
+		if err := relabel(repositories.LabelCards, card.ID, c.LabelIDs); err != nil {
+			return nil, err
+		}
+		for _, r := range c.Reminders {
+			reminder := &models.CardReminder{CardID: card.ID, UserID: userID, MinutesBefore: r.MinutesBefore}
+			if err := reminderRepo.Create(ctx, reminder); err != nil {
+				return nil, err
+			}
+		}
+		seen[card.ID.String()], seen[key] = true, true
+		result.ID, result.Status = card.ID, "created"
+		results = append(results, result)
+	}
+	for i, n := range doc.Notes {
+		result := importResult{Type: "note", Index: i, ID: n.ID, Title: n.Title}
+		content := noteContent(n.Content)
+		key := noteKey(n.Title, content)
+		if reason := duplicateReason(seen, n.ID, key); reason != "" {
+			result.Status, result.Reason = "skipped", reason
+			results = append(results, result)
+			continue
+		}
+		note := &models.Note{
+			ID:        n.ID,
+			Title:     n.Title,
+			Content:   content,
+			CreatedAt: orNow(n.CreatedAt, now),
+			UpdatedAt: orNow(n.UpdatedAt, now),
+			UserID:    userID,
+		}
+		if note.Title == "" {
+			note.Title = "Undefined"
+		}
+		if err := importWithID(&note.ID, func() (bool, error) { return noteRepo.Import(ctx, note) }); err != nil {
+			return nil, err
+		}
+		if err := relabel(repositories.LabelNotes, note.ID, n.LabelIDs); err != nil {
+			return nil, err
+		}
+		seen[note.ID.String()], seen[key] = true, true
+		result.ID, result.Status = note.ID, "created"
+		results = append(results, result)
+	}
+	if err := boardRepo.EnsureDefault(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// importBoards maps the boards of an archive to boards of the user,
+// reusing those with the same name that are not archived and creating the
+// others.
+func importBoards(ctx context.Context, repo repositories.BoardRepository, userID uuid.UUID, boards []export.Board, results []importResult) (map[uuid.UUID]uuid.UUID, []importResult, error) {
+	existing, err := repo.GetAll(ctx, userID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := map[string]uuid.UUID{}
+	for _, board := range *existing {
+		byName[strings.ToLower(board.Name)] = board.ID
+	}
+	ids := make(map[uuid.UUID]uuid.UUID, len(boards))
+	for i, b := range boards {
+		result := importResult{Type: "board", Index: i, ID: b.ID, Title: b.Name}
+		if id, ok := byName[strings.ToLower(b.Name)]; ok {
+			ids[b.ID] = id
+			result.ID, result.Status, result.Reason = id, "skipped", "duplicate name"
+			results = append(results, result)
+			continue
+		}
+		board := &models.Board{UserID: userID, Name: b.Name}
+		if err := repo.Create(ctx, board, nil); err != nil {
+			return nil, nil, err
+		}
+		if b.ArchivedAt != nil {
+			if err := repo.SetArchived(ctx, board.ID, userID, true); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			byName[strings.ToLower(b.Name)] = board.ID
+		}
+		ids[b.ID] = board.ID
+		result.ID, result.Status = board.ID, "created"
+		results = append(results, result)
+	}
+	return ids, results, nil
+}
+
+// importLabels maps the labels of an archive to labels of the user, reusing
+// those with the same name regardless of case and creating the others. In
+// replace mode the user has no labels left, so all of them are recreated.
+func importLabels(ctx context.Context, repo repositories.LabelRepository, userID uuid.UUID, labels []export.Label, results []importResult) (map[uuid.UUID]uuid.UUID, []importResult, error) {
+	existing, err := repo.GetAll(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := map[string]uuid.UUID{}
+	for _, label := range *existing {
+		byName[strings.ToLower(label.Name)] = label.ID
+	}
+	ids := make(map[uuid.UUID]uuid.UUID, len(labels))
+	for i, l := range labels {
+		name := strings.TrimSpace(l.Name)
+		result := importResult{Type: "label", Index: i, ID: l.ID, Title: name}
+		if id, ok := byName[strings.ToLower(name)]; ok {
+			ids[l.ID] = id
+			result.ID, result.Status, result.Reason = id, "skipped", "duplicate name"
+			results = append(results, result)
+			continue
+		}
+		label := &models.Label{UserID: userID, Name: name, Color: l.Color}
+		if err := repo.Create(ctx, label); err != nil {
+			return nil, nil, err
+		}
+		ids[l.ID], byName[strings.ToLower(name)] = label.ID, label.ID
+		result.ID, result.Status = label.ID, "created"
+		results = append(results, result)
+	}
+	return ids, results, nil
+}
+
+// importColumns maps the columns of an archive to columns of the user on
+// the board the column was mapped to, reusing those with the same name and
+// creating the others after them.
+func importColumns(ctx context.Context, repo repositories.BoardColumnRepository, userID uuid.UUID, columns []export.Column, boardIDs map[uuid.UUID]uuid.UUID, results []importResult) (map[uuid.UUID]models.BoardColumn, []importResult, error) {
+	existing, err := repo.GetAllForUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	columnKey := func(boardID uuid.UUID, name string) string {
+		return boardID.String() + "\x00" + strings.ToLower(name)
+	}
+	byName := map[string]models.BoardColumn{}
+	for _, column := range *existing {
+		byName[columnKey(column.BoardID, column.Name)] = column
+	}
+	order := make([]int, len(columns))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return columns[order[a]].Position < columns[order[b]].Position })
+	mapped := make(map[uuid.UUID]models.BoardColumn, len(columns))
+	for _, i := range order {
+		c := columns[i]
+		result := importResult{Type: "column", Index: i, ID: c.ID, Title: c.Name}
+		key := columnKey(boardIDs[c.BoardID], c.Name)
+		if column, ok := byName[key]; ok {
+			mapped[c.ID] = column
+			result.ID, result.Status, result.Reason = column.ID, "skipped", "duplicate name"
+			results = append(results, result)
+			continue
+		}
+		column := &models.BoardColumn{UserID: userID, BoardID: boardIDs[c.BoardID], Name: c.Name, Color: c.Color, Done: c.Done}
+		if err := repo.Create(ctx, column); err != nil {
+			return nil, nil, err
+		}
+		mapped[c.ID], byName[key] = *column, *column
+		result.ID, result.Status = column.ID, "created"
+		results = append(results, result)
+	}
+	return mapped, results, nil
+}
+
+func duplicateReason(seen map[string]bool, id uuid.UUID, key string) string {
+	switch {
+	case id != uuid.Nil && seen[id.String()]:
+		return "duplicate id"
+	case seen[key]:
+		return "duplicate content"
+	}
+	return ""
+}
+
+// importWithID runs insert with the archived ID, or a new one if there is
+// none or it is taken by another account.
+func importWithID(id *uuid.UUID, insert func() (bool, error)) error {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+	created, err := insert()
+	if err != nil || created {
+		return err
+	}
+	*id = uuid.New()
+	created, err = insert()
+	if err == nil && !created {
+		err = errors.New("generated id already exists")
+	}
+	return err
+}
+
+func cardKey(title, description string) string {
+	return "card\x00" + title + "\x00" + description
+}
+
+func noteKey(title, content string) string {
+	return "note\x00" + title + "\x00" + noteContent(json.RawMessage(content))
+}
+
+// noteContent normalizes the content of a note so equal documents compare
+// equal regardless of formatting.
+func noteContent(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if len(raw) == 0 || string(raw) == "null" {
+		return "{}"
+	}
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
+
+func orNow(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t
+}
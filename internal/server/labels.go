@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/export"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type labelRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// apply copies the fields present in the request to label and reports
+// those that are invalid.
+func (req *labelRequest) apply(label *models.Label) []fieldError {
+	var errs []fieldError
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		switch {
+		case name == "":
+			errs = append(errs, fieldError{Field: "name", Code: "required", Message: "Name is required"})
+		case utf8.RuneCountInString(name) > export.MaxLabelNameLength:
+			errs = append(errs, fieldError{Field: "name", Code: "too_long", Message: fmt.Sprintf("Name must be at most %d characters", export.MaxLabelNameLength)})
+		default:
+			label.Name = name
+		}
+	}
+	if req.Color != nil {
+		if *req.Color != "" && !columnColor.MatchString(*req.Color) {
+			errs = append(errs, fieldError{Field: "color", Code: "invalid", Message: "Color must look like #1a2b3c"})
+		} else {
+			label.Color = *req.Color
+		}
+	}
+	return errs
+}
+
+// labelQuery parses the optional labels query parameter, a comma separated
+// list of label IDs, and label_match, which is any (the default) or all.
+func labelQuery(c *fiber.Ctx) (repositories.LabelMatch, error) {
+	var match repositories.LabelMatch
+	switch c.Query("label_match", "any") {
+	case "any":
+	case "all":
+		match.All = true
+	default:
+		return match, errors.New("invalid label_match")
+	}
+	raw := c.Query("labels")
+	if raw == "" {
+		return match, nil
+	}
+	for _, part := range strings.Split(raw, ",") {
+		id, err := uuid.Parse(strings.TrimSpace(part))
+		if err != nil {
+			return match, errors.New("invalid labels")
+		}
+		match.LabelIDs = append(match.LabelIDs, id)
+	}
+	return match, nil
+}
+
+// getLabels lists the labels of the user with the number of cards and notes
+// each is on.
+func (s *FiberServer) getLabels(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	labels, err := repositories.NewLabelRepository(s.db.DB()).GetAll(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch labels"})
+	}
+	return c.JSON(fiber.Map{"labels": labels})
+}
+
+func (s *FiberServer) createLabel(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req labelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if req.Name == nil {
+		req.Name = new(string)
+	}
+	label := models.Label{UserID: currentUser.ID}
+	if errs := req.apply(&label); len(errs) > 0 {
+		return validationFailed(c, "Invalid label", errs)
+	}
+	err := repositories.NewLabelRepository(s.db.DB()).Create(c.Context(), &label)
+	if errors.Is(err, repositories.ErrLabelExists) {
+		return labelExists(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create label"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Label created successfully", "label": label})
+}
+
+func (s *FiberServer) updateLabel(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var req labelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	repo := repositories.NewLabelRepository(s.db.DB())
+	label, err := repo.GetByID(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrLabelNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Label not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update label"})
+	}
+	if errs := req.apply(label); len(errs) > 0 {
+		return validationFailed(c, "Invalid label", errs)
+	}
+	err = repo.Update(c.Context(), label)
+	switch {
+	case errors.Is(err, repositories.ErrLabelNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Label not found"})
+	case errors.Is(err, repositories.ErrLabelExists):
+		return labelExists(c)
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update label"})
+	}
+	return c.JSON(fiber.Map{"message": "Label updated successfully", "label": label})
+}
+
+func labelExists(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"message": "Invalid label",
+		"errors":  []fieldError{{Field: "name", Code: "taken", Message: "A label with this name already exists"}},
+	})
+}
+
+// deleteLabel deletes a label and takes it off every card and note.
+func (s *FiberServer) deleteLabel(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	err = repositories.NewLabelRepository(s.db.DB()).Delete(c.Context(), id, currentUser.ID)
+	if errors.Is(err, repositories.ErrLabelNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Label not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete label"})
+	}
+	return c.JSON(fiber.Map{"message": "Label deleted successfully"})
+}
+
+// itemLabels returns the label IDs of a card or note of the user, or an
+// error if there is no such item.
+func (s *FiberServer) itemLabels(ctx context.Context, target repositories.LabelTarget, id uuid.UUID, userID uuid.UUID) ([]uuid.UUID, error) {
+	if target == repositories.LabelCards {
+		card, err := repositories.NewCardRepository(s.db.DB()).GetByID(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
+		return card.LabelIDs, nil
+	}
+	note, err := repositories.NewNoteRepository(s.db.DB()).GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return note.LabelIDs, nil
+}
+
+func itemNotFound(c *fiber.Ctx, target repositories.LabelTarget) error {
+	message := "Note not found"
+	if target == repositories.LabelCards {
+		message = "Card not found"
+	}
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": message})
+}
+
+// setLabels replaces the labels of a card or note with label_ids.
+func (s *FiberServer) setLabels(target repositories.LabelTarget) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		currentUser := userFromContext(c)
+		id, err := uuid.Parse(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+		}
+		var req struct {
+			LabelIDs []uuid.UUID `json:"label_ids"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+		}
+		if _, err := s.itemLabels(c.Context(), target, id, currentUser.ID); err != nil {
+			return itemNotFound(c, target)
+		}
+		err = repositories.NewLabelRepository(s.db.DB()).Set(c.Context(), target, id, req.LabelIDs, currentUser.ID)
+		if errors.Is(err, repositories.ErrLabelNotFound) {
+			return validationFailed(c, "Invalid labels", []fieldError{{Field: "label_ids", Code: "unknown_label", Message: "Every label must be one of your labels"}})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update labels"})
+		}
+		return s.labelsUpdated(c, target, id)
+	}
+}
+
+// addLabel puts the label labelId on a card or note. Adding a label that
+// is already there does nothing.
+func (s *FiberServer) addLabel(target repositories.LabelTarget) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return s.changeLabel(c, target, true)
+	}
+}
+
+// removeLabel takes the label labelId off a card or note. Removing a label
+// that is not there does nothing.
+func (s *FiberServer) removeLabel(target repositories.LabelTarget) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return s.changeLabel(c, target, false)
+	}
+}
+
+func (s *FiberServer) changeLabel(c *fiber.Ctx, target repositories.LabelTarget, add bool) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	labelID, err := uuid.Parse(c.Params("labelId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	if _, err := s.itemLabels(c.Context(), target, id, currentUser.ID); err != nil {
+		return itemNotFound(c, target)
+	}
+	repo := repositories.NewLabelRepository(s.db.DB())
+	if add {
+		err = repo.Assign(c.Context(), target, id, labelID, currentUser.ID)
+	} else {
+		err = repo.Unassign(c.Context(), target, id, labelID, currentUser.ID)
+	}
+	if errors.Is(err, repositories.ErrLabelNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Label not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update labels"})
+	}
+	return s.labelsUpdated(c, target, id)
+}
+
+func (s *FiberServer) labelsUpdated(c *fiber.Ctx, target repositories.LabelTarget, id uuid.UUID) error {
+	labelIDs, err := s.itemLabels(c.Context(), target, id, userFromContext(c).ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update labels"})
+	}
+	return c.JSON(fiber.Map{"message": "Labels updated successfully", "label_ids": labelIDs})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"rytr/internal/database/repositories"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestLabelQuery(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   repositories.LabelMatch
+	}{
+		{"no filter", "", http.StatusOK, repositories.LabelMatch{}},
+		{"one label", "?labels=" + a.String(), http.StatusOK, repositories.LabelMatch{LabelIDs: []uuid.UUID{a}}},
+		{"any is the default", "?labels=" + a.String() + "," + b.String(), http.StatusOK, repositories.LabelMatch{LabelIDs: []uuid.UUID{a, b}}},
+		{"any", "?label_match=any&labels=" + a.String(), http.StatusOK, repositories.LabelMatch{LabelIDs: []uuid.UUID{a}}},
+		{"all", "?label_match=all&labels=" + a.String() + ",%20" + b.String(), http.StatusOK, repositories.LabelMatch{LabelIDs: []uuid.UUID{a, b}, All: true}},
+		{"all without labels", "?label_match=all", http.StatusOK, repositories.LabelMatch{All: true}},
+		{"unknown match", "?label_match=some&labels=" + a.String(), http.StatusBadRequest, repositories.LabelMatch{}},
+		{"invalid id", "?labels=" + a.String() + ",urgent", http.StatusBadRequest, repositories.LabelMatch{}},
+		{"empty id", "?labels=" + a.String() + ",", http.StatusBadRequest, repositories.LabelMatch{}},
+	}
+	for _, tt := range tests {
+		var got repositories.LabelMatch
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			match, err := labelQuery(c)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+			}
+			got = match
+			return c.SendStatus(fiber.StatusOK)
+		})
+		req, err := http.NewRequest("GET", "/"+tt.query, nil)
+		if err != nil {
+			t.Fatalf("error creating request. Err: %v", err)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("error making request to server. Err: %v", err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: match = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"log"
+	"math"
+	"rytr/internal/database/models"
+	"rytr/internal/lockout"
+	"rytr/internal/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newLoginLimiters builds the per-IP and per-account limiters guarding login.
+// LOGIN_ATTEMPT_STORE=postgres shares the counters between instances.
+func (s *FiberServer) newLoginLimiters() (ip *lockout.Limiter, account *lockout.Limiter) {
+	var store lockout.Store = lockout.NewMemoryStore()
+	if strings.ToLower(utils.GetEnv("LOGIN_ATTEMPT_STORE", "memory")) == "postgres" {
+		store = lockout.NewPostgresStore(s.db.DB())
+	}
+	base := utils.GetEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute)
+	max := utils.GetEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour)
+	window := utils.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour)
+	ip = lockout.NewLimiter(store, "ip", lockout.Policy{
+		Threshold: utils.GetEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		BaseDelay: base,
+		MaxDelay:  max,
+		Window:    window,
+	})
+	account = lockout.NewLimiter(store, "account", lockout.Policy{
+		Threshold: utils.GetEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		BaseDelay: base,
+		MaxDelay:  max,
+		Window:    window,
+	})
+	s.loginAttemptStore = store
+	return ip, account
+}
+
+// newMailLimiters builds the per-IP and per-address limiters guarding the
+// endpoints that mail links to unauthenticated callers. They share the store
+// of the login limiters, so newLoginLimiters must run first.
+func (s *FiberServer) newMailLimiters() (ip *lockout.Limiter, account *lockout.Limiter) {
+	base := utils.GetEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute)
+	max := utils.GetEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour)
+	window := utils.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour)
+	ip = lockout.NewLimiter(s.loginAttemptStore, "mail-ip", lockout.Policy{
+		Threshold: utils.GetEnvInt("MAIL_IP_MAX_REQUESTS", 10),
+		BaseDelay: base,
+		MaxDelay:  max,
+		Window:    window,
+	})
+	account = lockout.NewLimiter(s.loginAttemptStore, "mail-account", lockout.Policy{
+		Threshold: utils.GetEnvInt("MAIL_MAX_REQUESTS", 3),
+		BaseDelay: base,
+		MaxDelay:  max,
+		Window:    window,
+	})
+	return ip, account
+}
+
+func (s *FiberServer) pruneLoginAttempts(ctx context.Context) error {
+	window := utils.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour)
+	return s.loginAttemptStore.Prune(ctx, time.Now().Add(-window))
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginWait returns how long the client must wait before it may try to log
+// into account again.
+func (s *FiberServer) loginWait(ctx context.Context, ip string, account string) (time.Duration, error) {
+	ipWait, err := s.ipLimiter.Check(ctx, ip)
+	if err != nil {
+		return 0, err
+	}
+	accountWait, err := s.accountLimiter.Check(ctx, accountKey(account))
+	if err != nil {
+		return 0, err
+	}
+	return max(ipWait, accountWait), nil
+}
+
+func (s *FiberServer) recordLoginFailure(ctx context.Context, ip string, account string) {
+	if _, err := s.ipLimiter.Fail(ctx, ip); err != nil {
+		log.Printf("failed to record login failure: %v", err)
+	}
+	if _, err := s.accountLimiter.Fail(ctx, accountKey(account)); err != nil {
+		log.Printf("failed to record login failure: %v", err)
+	}
+}
+
+func (s *FiberServer) resetLoginFailures(ctx context.Context, account string) {
+	if err := s.accountLimiter.Reset(ctx, accountKey(account)); err != nil {
+		log.Printf("failed to reset login failures: %v", err)
+	}
+}
+
+// mailWait counts a request to mail account and returns how long the client
+// must wait before it may make it, zero if it may go ahead now.
+func (s *FiberServer) mailWait(ctx context.Context, ip string, account string) (time.Duration, error) {
+	ipWait, err := s.mailIPLimiter.Check(ctx, ip)
+	if err != nil {
+		return 0, err
+	}
+	accountWait, err := s.mailAccountLimiter.Check(ctx, accountKey(account))
+	if err != nil {
+		return 0, err
+	}
+	if wait := max(ipWait, accountWait); wait > 0 {
+		return wait, nil
+	}
+	if _, err := s.mailIPLimiter.Fail(ctx, ip); err != nil {
+		return 0, err
+	}
+	if _, err := s.mailAccountLimiter.Fail(ctx, accountKey(account)); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+func tooManyRequests(c *fiber.Ctx, wait time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"message": "Too many requests, try again later"})
+}
+
+func tooManyAttempts(c *fiber.Ctx, wait time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"message": "Too many failed attempts, try again later"})
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// checkCredentials verifies password against user, which may be nil when no
+// account exists. A hash is compared either way so that response times do not
+// reveal whether the address is registered.
+func checkCredentials(user *models.User, password string) bool {
+	// Accounts created through an identity provider have no password.
+	if user == nil || user.Password == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = utils.HashPassword("rytr-dummy-password")
+		})
+		utils.CheckPasswordHash(password, dummyHash)
+		return false
+	}
+	return utils.CheckPasswordHash(password, user.Password)
+}
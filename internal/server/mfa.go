@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const recoveryCodeCount = 10
+
+var totpIssuer = utils.GetEnv("TOTP_ISSUER", "rytr")
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func (s *FiberServer) verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		userRepo := repositories.NewUserRepository(s.db.DB())
+		return userRepo.UseTOTPStep(ctx, user.ID, step)
+	}
+	if recoveryCode != "" {
+		repo := repositories.NewRecoveryCodeRepository(s.db.DB())
+		return repo.Consume(ctx, user.ID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the
+// new plaintext codes, which are shown exactly once.
+func (s *FiberServer) generateRecoveryCodes(ctx context.Context, user *models.User) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = utils.HashToken(code)
+	}
+	repo := repositories.NewRecoveryCodeRepository(s.db.DB())
+	if err := repo.Replace(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// loginSecondFactor exchanges the challenge token returned by login and a
+// second factor for an access and a refresh token.
+func (s *FiberServer) loginSecondFactor(c *fiber.Ctx) error {
+	var req dto.TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid json body"})
+	}
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "challenge_token and code or recovery_code are required"})
+	}
+	userID, err := s.parseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid or expired challenge"})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	user, err := userRepo.GetByID(c.Context(), userID)
+	if err != nil || user.TOTPEnabledAt == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid or expired challenge"})
+	}
+	// Wrong codes count against the same budget as wrong passwords.
+	wait, err := s.loginWait(c.Context(), c.IP(), user.Email)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to verify code"})
+	}
+	if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+	ok, err := s.verifySecondFactor(c.Context(), user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to verify code"})
+	}
+	if !ok {
+		s.recordLoginFailure(c.Context(), c.IP(), user.Email)
+		s.audit(c, models.AuditLoginFailed, user.ID, fiber.Map{"email": user.Email, "reason": "invalid_second_factor"})
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid code"})
+	}
+	method := "totp"
+	if req.Code == "" {
+		method = "recovery_code"
+	}
+	return s.completeLogin(c, user, method)
+}
+
+func (s *FiberServer) getTwoFactorStatus(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewRecoveryCodeRepository(s.db.DB())
+	remaining, err := repo.CountUnused(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch two-factor status"})
+	}
+	return c.JSON(fiber.Map{
+		"totp_enabled":             currentUser.TOTPEnabledAt != nil,
+		"totp_enabled_at":          currentUser.TOTPEnabledAt,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// setupTOTP generates a new secret. It only takes effect after confirmTOTP.
+func (s *FiberServer) setupTOTP(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	if currentUser.TOTPEnabledAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Two-factor authentication is already enabled"})
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to set up two-factor authentication"})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.SetPendingTOTPSecret(c.Context(), currentUser.ID, secret); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to set up two-factor authentication"})
+	}
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(totpIssuer, currentUser.Email, secret),
+	})
+}
+
+func (s *FiberServer) confirmTOTP(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req dto.TOTPCodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "code is required"})
+	}
+	if currentUser.TOTPSecret == "" || currentUser.TOTPEnabledAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "No pending two-factor setup"})
+	}
+	step, ok := utils.ValidateTOTP(currentUser.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid code"})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if _, err := userRepo.UseTOTPStep(c.Context(), currentUser.ID, step); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to enable two-factor authentication"})
+	}
+	if err := userRepo.EnableTOTP(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to enable two-factor authentication"})
+	}
+	codes, err := s.generateRecoveryCodes(c.Context(), currentUser)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate recovery codes"})
+	}
+	s.audit(c, models.AuditTOTPEnabled, currentUser.ID, nil)
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func (s *FiberServer) disableTOTP(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req dto.TwoFactorDisableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if currentUser.TOTPEnabledAt == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Two-factor authentication is not enabled"})
+	}
+	// Users without a password confirm with the code alone; passing it to
+	// reauthenticate as well would use it up before it is checked below.
+	if currentUser.Password != "" {
+		if status, message := s.reauthenticate(c, currentUser, req.Password, "", ""); status != 0 {
+			return c.Status(status).JSON(fiber.Map{"message": message})
+		}
+	}
+	ok, err := s.verifySecondFactor(c.Context(), currentUser, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to verify code"})
+	}
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid code"})
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.DisableTOTP(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to disable two-factor authentication"})
+	}
+	repo := repositories.NewRecoveryCodeRepository(s.db.DB())
+	if err := repo.DeleteAll(c.Context(), currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to delete recovery codes"})
+	}
+	s.audit(c, models.AuditTOTPDisabled, currentUser.ID, nil)
+	return c.JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+func (s *FiberServer) regenerateRecoveryCodes(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req dto.PasswordConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if currentUser.TOTPEnabledAt == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Two-factor authentication is not enabled"})
+	}
+	if status, message := s.reauthenticate(c, currentUser, req.Password, req.Code, req.RecoveryCode); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"message": message})
+	}
+	codes, err := s.generateRecoveryCodes(c.Context(), currentUser)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate recovery codes"})
+	}
+	s.audit(c, models.AuditRecoveryCodesRegenerated, currentUser.ID, nil)
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
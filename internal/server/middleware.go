@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Keys under which requireAuth stores the caller in c.Locals.
+const (
+	userLocalsKey  = "currentUser"
+	tokenLocalsKey = "accessToken"
+)
+
+// tokenClaims are the claims of the JWTs issued by the server. The subject is
+// the user ID, which unlike the email address never changes.
+type tokenClaims struct {
+	Type      string `json:"typ"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+var errUnauthorized = errors.New("invalid or expired token")
+
+// requireAuth authenticates the request with either an access token or a
+// personal access token and loads the user once for all handlers.
+func (s *FiberServer) requireAuth(c *fiber.Ctx) error {
+	raw, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || raw == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Missing or malformed token"})
+	}
+	var (
+		user *models.User
+		err  error
+	)
+	if strings.HasPrefix(raw, personalAccessTokenPrefix) {
+		user, err = s.authenticatePersonalAccessToken(c, raw)
+	} else {
+		user, err = s.authenticateAccessToken(c, raw)
+	}
+	if err != nil {
+		if errors.Is(err, errUnauthorized) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid or expired token"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to authenticate"})
+	}
+	if user.DisabledAt != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Account disabled"})
+	}
+	c.Locals(userLocalsKey, user)
+	return c.Next()
+}
+
+func (s *FiberServer) authenticateAccessToken(c *fiber.Ctx, raw string) (*models.User, error) {
+	claims := tokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, &claims, s.keys.Keyfunc, jwt.WithValidMethods(s.keys.Methods()), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != tokenTypeAccess {
+		return nil, errUnauthorized
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	if err := s.checkSession(c.Context(), sessionID, userID); err != nil {
+		return nil, err
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	user, err := userRepo.GetByID(c.Context(), userID)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	c.Locals(sessionLocalsKey, sessionID)
+	return user, nil
+}
+
+func (s *FiberServer) authenticatePersonalAccessToken(c *fiber.Ctx, raw string) (*models.User, error) {
+	repo := repositories.NewPersonalAccessTokenRepository(s.db.DB())
+	token, err := repo.GetByHash(c.Context(), utils.HashToken(raw))
+	if err != nil {
+		if errors.Is(err, repositories.ErrPersonalAccessTokenNotFound) {
+			return nil, errUnauthorized
+		}
+		return nil, err
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, errUnauthorized
+	}
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	user, err := userRepo.GetByID(c.Context(), token.UserID)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	// Such accounts cannot log in either, so their tokens must not work.
+	if user.PasswordResetRequired || user.DeletionScheduledAt != nil {
+		return nil, errUnauthorized
+	}
+	if err := repo.TouchLastUsed(c.Context(), token.ID); err != nil {
+		return nil, err
+	}
+	c.Locals(tokenLocalsKey, token)
+	return user, nil
+}
+
+// userFromContext returns the user loaded by requireAuth. It must only be
+// used by handlers registered behind requireAuth.
+func userFromContext(c *fiber.Ctx) *models.User {
+	return c.Locals(userLocalsKey).(*models.User)
+}
+
+// personalAccessTokenFromContext returns the personal access token the
+// request was authenticated with, or nil for interactive sessions.
+func personalAccessTokenFromContext(c *fiber.Ctx) *models.PersonalAccessToken {
+	token, _ := c.Locals(tokenLocalsKey).(*models.PersonalAccessToken)
+	return token
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/oidc"
+	"rytr/internal/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateCookie  = "rytr_oidc"
+	oidcStatePurpose = "oidc_state"
+	oidcCookiePath   = "/auth/oidc"
+)
+
+var (
+	oidcStateTTL = 10 * time.Minute
+	// oidcAppRedirectURL is the client page that receives the result of a
+	// sign-in in its URL fragment.
+	oidcAppRedirectURL = utils.GetEnv("OIDC_APP_REDIRECT_URL", appBaseURL+"/auth/callback")
+)
+
+var (
+	errOIDCAccountExists = errors.New("an account with this email already exists")
+	errOIDCEmailMissing  = errors.New("identity provider returned no email")
+)
+
+// oidcState is kept in a signed cookie between the redirect to the provider
+// and the callback.
+type oidcState struct {
+	Provider  string `json:"p"`
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func (s *FiberServer) getOIDCProviders(c *fiber.Ctx) error {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return c.JSON(fiber.Map{"providers": names})
+}
+
+// oidcLogin sends the browser to the provider's sign-in page.
+func (s *FiberServer) oidcLogin(c *fiber.Ctx) error {
+	provider, ok := s.oidcProviders[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Unknown identity provider"})
+	}
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	stored := oidcState{
+		Provider:  provider.Name,
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  oauth2.GenerateVerifier(),
+		ExpiresAt: time.Now().Add(oidcStateTTL).Unix(),
+	}
+	authURL, err := provider.AuthCodeURL(c.Context(), stored.State, stored.Nonce, stored.Verifier)
+	if err != nil {
+		log.Printf("failed to reach identity provider %s: %v", provider.Name, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"message": "Identity provider unavailable"})
+	}
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    utils.SignValue(oidcStatePurpose, value),
+		Path:     oidcCookiePath,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		Secure:   strings.HasPrefix(provider.RedirectURL, "https://"),
+		HTTPOnly: true,
+		// Lax so the cookie survives the top-level redirect back from the
+		// provider.
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// oidcCallback finishes a sign-in and redirects to the client with either
+// tokens, a two-factor challenge or an error code in the URL fragment.
+func (s *FiberServer) oidcCallback(c *fiber.Ctx) error {
+	provider, ok := s.oidcProviders[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Unknown identity provider"})
+	}
+	raw := c.Cookies(oidcStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Path:     oidcCookiePath,
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+	})
+	if errCode := c.Query("error"); errCode != "" {
+		return oidcRedirect(c, fiber.Map{"error": errCode})
+	}
+
+	var stored oidcState
+	value, ok := utils.VerifySignedValue(oidcStatePurpose, raw)
+	if !ok || json.Unmarshal(value, &stored) != nil ||
+		stored.Provider != provider.Name ||
+		stored.State != c.Query("state") ||
+		time.Now().Unix() > stored.ExpiresAt {
+		return oidcRedirect(c, fiber.Map{"error": "invalid_state"})
+	}
+
+	identity, err := provider.Exchange(c.Context(), c.Query("code"), stored.Verifier, stored.Nonce)
+	if err != nil {
+		log.Printf("failed to exchange code with %s: %v", provider.Name, err)
+		return oidcRedirect(c, fiber.Map{"error": "exchange_failed"})
+	}
+	user, err := s.userForIdentity(c, provider.Name, identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errOIDCAccountExists):
+			return oidcRedirect(c, fiber.Map{"error": "account_exists"})
+		case errors.Is(err, errOIDCEmailMissing):
+			return oidcRedirect(c, fiber.Map{"error": "email_required"})
+		}
+		log.Printf("failed to sign in with %s: %v", provider.Name, err)
+		return oidcRedirect(c, fiber.Map{"error": "server_error"})
+	}
+	if user.DisabledAt != nil {
+		return oidcRedirect(c, fiber.Map{"error": "account_disabled"})
+	}
+	if emailVerificationMode == verificationLogin && user.EmailVerifiedAt == nil {
+		return oidcRedirect(c, fiber.Map{"error": "email_not_verified"})
+	}
+	if user.TOTPEnabledAt != nil {
+		challenge, err := s.signChallengeToken(user)
+		if err != nil {
+			return oidcRedirect(c, fiber.Map{"error": "server_error"})
+		}
+		return oidcRedirect(c, fiber.Map{"mfa_required": true, "challenge_token": challenge})
+	}
+	tokens, err := s.startSession(c, user, "oidc:"+provider.Name)
+	if err != nil {
+		return oidcRedirect(c, fiber.Map{"error": "server_error"})
+	}
+	return oidcRedirect(c, tokens)
+}
+
+// oidcRedirect hands params to the client in the URL fragment, which is
+// never sent to servers or written to access logs.
+func oidcRedirect(c *fiber.Ctx, params fiber.Map) error {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, fmt.Sprint(v))
+	}
+	return c.Redirect(oidcAppRedirectURL+"#"+values.Encode(), fiber.StatusFound)
+}
+
+// userForIdentity returns the user linked to identity. Unknown identities are
+// linked to the account with the same email if the provider verified it, or
+// get a new account without a password.
+func (s *FiberServer) userForIdentity(c *fiber.Ctx, provider string, identity *oidc.Identity) (*models.User, error) {
+	ctx := c.Context()
+	identityRepo := repositories.NewUserIdentityRepository(s.db.DB())
+	userRepo := repositories.NewUserRepository(s.db.DB())
+
+	linked, err := identityRepo.GetBySubject(ctx, provider, identity.Subject)
+	if err == nil {
+		if err := identityRepo.TouchLastLogin(ctx, linked.ID); err != nil {
+			return nil, err
+		}
+		return userRepo.GetByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, repositories.ErrUserIdentityNotFound) {
+		return nil, err
+	}
+	if identity.Email == "" {
+		return nil, errOIDCEmailMissing
+	}
+
+	user, err := userRepo.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Both sides have to vouch for the address. Linking to an unverified
+		// local account would let whoever registered it first keep a
+		// password to the account.
+		if !identity.EmailVerified || user.EmailVerifiedAt == nil {
+			return nil, errOIDCAccountExists
+		}
+	case err.Error() == "user not found":
+		user, err = s.createIdentityUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	linked = &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	if err := identityRepo.Create(ctx, linked); err != nil {
+		return nil, err
+	}
+	s.audit(c, models.AuditIdentityLinked, user.ID, fiber.Map{"identity_id": linked.ID, "provider": provider})
+	return user, nil
+}
+
+func (s *FiberServer) createIdentityUser(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	firstName, lastName := identity.GivenName, identity.FamilyName
+	if firstName == "" {
+		firstName, lastName, _ = strings.Cut(identity.Name, " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(identity.Email, "@")
+	}
+	user := models.User{
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     identity.Email,
+	}
+	repo := repositories.NewUserRepository(s.db.DB())
+	if err := repo.Create(ctx, &user); err != nil {
+		return nil, err
+	}
+	if !identity.EmailVerified {
+		if err := s.sendVerificationEmail(ctx, &user); err != nil {
+			log.Printf("failed to send verification email: %v", err)
+		}
+		return &user, nil
+	}
+	if err := repo.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return &user, nil
+}
+
+func (s *FiberServer) getIdentities(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewUserIdentityRepository(s.db.DB())
+	identities, err := repo.GetAll(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch identities"})
+	}
+	return c.JSON(fiber.Map{"identities": identities})
+}
+
+func (s *FiberServer) deleteIdentity(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewUserIdentityRepository(s.db.DB())
+	// Never take away the last way to sign in.
+	if currentUser.Password == "" {
+		identities, err := repo.GetAll(c.Context(), currentUser.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to unlink identity"})
+		}
+		if len(*identities) <= 1 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": "Set a password before unlinking your last identity provider"})
+		}
+	}
+	if err := repo.Delete(c.Context(), id, currentUser.ID); err != nil {
+		if errors.Is(err, repositories.ErrUserIdentityNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Identity not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to unlink identity"})
+	}
+	s.audit(c, models.AuditIdentityUnlinked, currentUser.ID, fiber.Map{"identity_id": id})
+	return c.JSON(fiber.Map{"message": "Identity unlinked"})
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/mailer"
+	"rytr/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var passwordResetTTL = utils.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+
+// forgotPassword starts the reset flow for unauthenticated users. The work is
+// done in the background and the response is identical for registered and
+// unknown addresses, so neither content nor timing reveals whether an
+// account exists. Clients and addresses asking too often are throttled
+// before any work is started.
+func (s *FiberServer) forgotPassword(c *fiber.Ctx) error {
+	var req dto.EmailRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "email is required"})
+	}
+	wait, err := s.mailWait(c.Context(), c.IP(), req.Email)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to start password reset"})
+	}
+	if wait > 0 {
+		return tooManyRequests(c, wait)
+	}
+	go func(email string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.sendPasswordResetEmail(ctx, email); err != nil {
+			log.Printf("failed to start password reset: %v", err)
+		}
+	}(req.Email)
+	return c.JSON(fiber.Map{"message": "If an account exists for this address, a password reset link has been sent"})
+}
+
+func (s *FiberServer) sendPasswordResetEmail(ctx context.Context, email string) error {
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	user, err := userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+	// Only the most recent link stays valid.
+	tokenRepo := repositories.NewUserTokenRepository(s.db.DB())
+	if err := tokenRepo.DeleteForUser(ctx, user.ID, models.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+	token, err := s.createUserToken(ctx, user.ID, models.TokenPurposePasswordReset, "", passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your rytr account. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %s. If you did not ask for this you can ignore this email.\n",
+			user.FirstName, appLink("/reset-password", token), passwordResetTTL),
+	})
+	return nil
+}
+
+func (s *FiberServer) confirmPasswordReset(c *fiber.Ctx) error {
+	var req dto.PasswordResetConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	if req.Token == "" || req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Token and new password are required"})
+	}
+	errs, err := s.passwordErrors(c.Context(), "new_password", req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reset password"})
+	}
+	if len(errs) > 0 {
+		return invalidPassword(c, errs)
+	}
+	hash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reset password"})
+	}
+
+	token, err := s.consumeUserToken(c.Context(), models.TokenPurposePasswordReset, req.Token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserTokenInvalid) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid or expired reset link"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reset password"})
+	}
+
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	if err := userRepo.SetPassword(c.Context(), token.UserID, hash); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reset password"})
+	}
+	// The link was delivered to the account's address, which proves ownership.
+	if err := userRepo.MarkEmailVerified(c.Context(), token.UserID); err != nil {
+		log.Printf("failed to mark email verified: %v", err)
+	}
+	if err := s.revokeAllSessions(c.Context(), token.UserID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke sessions"})
+	}
+	tokenRepo := repositories.NewUserTokenRepository(s.db.DB())
+	if err := tokenRepo.DeleteForUser(c.Context(), token.UserID, models.TokenPurposePasswordReset); err != nil {
+		log.Printf("failed to delete password reset tokens: %v", err)
+	}
+	s.audit(c, models.AuditPasswordReset, token.UserID, nil)
+
+	return c.JSON(fiber.Map{"message": "Password reset successful"})
+}
+
+// upgradePasswordHash rehashes the password of a user who just logged in if
+// the stored hash uses an older algorithm or parameters. Failures only delay
+// the upgrade to the next login.
+func (s *FiberServer) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	if !utils.PasswordNeedsRehash(user.Password) {
+		return
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("failed to rehash password: %v", err)
+		return
+	}
+	repo := repositories.NewUserRepository(s.db.DB())
+	if err := repo.UpdatePasswordHash(ctx, user.ID, user.Password, hash); err != nil {
+		log.Printf("failed to rehash password: %v", err)
+		return
+	}
+	user.Password = hash
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// reauthWindow is how long after signing in with an identity provider a user
+// without a password may confirm sensitive changes without a code.
+var reauthWindow = utils.GetEnvDuration("REAUTH_WINDOW", 10*time.Minute)
+
+// reauthenticate checks that the caller is still the owner of the account
+// before a sensitive change. Users with a password have to give it. Users
+// without one give a two-factor code or recovery code if they have two-factor
+// authentication enabled, or else must have signed in within reauthWindow.
+// On failure it returns the status and message to answer with; a zero status
+// means the caller is confirmed.
+func (s *FiberServer) reauthenticate(c *fiber.Ctx, user *models.User, password, code, recoveryCode string) (int, string) {
+	if user.Password != "" {
+		if password == "" {
+			return fiber.StatusBadRequest, "Password is required"
+		}
+		if !utils.CheckPasswordHash(password, user.Password) {
+			return fiber.StatusUnauthorized, "Incorrect password"
+		}
+		return 0, ""
+	}
+
+	if user.TOTPEnabledAt != nil && (code != "" || recoveryCode != "") {
+		ok, err := s.verifySecondFactor(c.Context(), user, code, recoveryCode)
+		if err != nil {
+			return fiber.StatusInternalServerError, "Failed to verify code"
+		}
+		if !ok {
+			return fiber.StatusUnauthorized, "Invalid code"
+		}
+		return 0, ""
+	}
+
+	// Refreshing keeps the session, so its creation is the last sign-in.
+	if sessionID := sessionIDFromContext(c); sessionID != uuid.Nil {
+		session, err := repositories.NewSessionRepository(s.db.DB()).GetActive(c.Context(), sessionID, user.ID)
+		if err == nil && time.Since(session.CreatedAt) < reauthWindow {
+			return 0, ""
+		}
+	}
+	return fiber.StatusConflict, "Set a password or sign in again to confirm this change"
+}
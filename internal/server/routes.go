@@ -1,64 +1,144 @@
 package server
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"runtime"
+	"log"
 	"rytr/internal/database/dto"
 	"rytr/internal/database/models"
 	"rytr/internal/database/repositories"
 	"rytr/internal/utils"
-	"time"
 
-	jwtware "github.com/gofiber/contrib/jwt"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
 	"github.com/google/uuid"
 	"google.golang.org/genai"
 )
 
-func bToMb(b uint64) uint64 {
-	return b / 1024 / 1024
-}
-
 func (s *FiberServer) RegisterFiberRoutes() {
 	s.App.Post("/login", s.login)
+	s.App.Post("/login/2fa", s.loginSecondFactor)
 	s.App.Post("/register", s.registerUser)
+	s.App.Post("/token/refresh", s.refreshToken)
+	s.App.Post("/logout", s.logout)
+	s.App.Get("/verify-email", s.verifyEmail)
+	s.App.Post("/verify-email", s.verifyEmail)
+	s.App.Post("/verify-email/resend", s.resendVerificationEmail)
+	s.App.Post("/forgot-password", s.forgotPassword)
+	s.App.Post("/reset-password/confirm", s.confirmPasswordReset)
+	s.App.Post("/email-change/confirm", s.confirmEmailChange)
+	s.App.Post("/email-change/cancel", s.cancelEmailChange)
+	s.App.Get("/auth/oidc", s.getOIDCProviders)
+	s.App.Get("/auth/oidc/:provider/login", s.oidcLogin)
+	s.App.Get("/auth/oidc/:provider/callback", s.oidcCallback)
 	s.App.Get("/health", s.healthHandler)
-	// endpoint to monitor memory
-	s.App.Get("/memory", func(c *fiber.Ctx) error {
-		var m runtime.MemStats
-		runtime.ReadMemStats(&m)
-		memoryInfo := fmt.Sprintf("Alloc = %v MiB, TotalAlloc = %v MiB, Sys = %v MiB, NumGC = %v",
-			bToMb(m.Alloc), bToMb(m.TotalAlloc), bToMb(m.Sys), m.NumGC)
-		return c.SendString(memoryInfo)
-	})
-	secret := os.Getenv("SECRET_KEY")
-	s.App.Use(jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte(secret)},
-	}))
-
-	s.App.Post("/reset-password", s.resetPassword)
-	s.App.Get("/profile", s.getUserProfile)
-	s.App.Put("/profile", s.updateUserProfile)
-
-	s.App.Post("/cards", s.createCard)
-	s.App.Get("/cards", s.getAllCards)
-	s.App.Get("/cards/pending", s.getPendingCards)
-	s.App.Get("/cards/:id<int />", s.getSingleCard)
-	s.App.Put("/cards/:id<int />", s.updateCard)
-	s.App.Put("/cards/status/:id<int />", s.updateCardStatus)
-	s.App.Delete("/cards/:id<int />", s.deleteCard)
-
-	s.App.Post("/notes", s.createNote)
-	s.App.Get("/notes", s.getAllNotes)
-	s.App.Get("/notes/:id", s.getSingleNote)
-	s.App.Put("/notes/:id", s.updateNote)
-	s.App.Delete("/notes/:id", s.deleteNote)
-
-	s.App.Get(("/search"), s.searchData)
-
-	s.App.Post("/gemini", s.geminiHandler)
+	s.App.Get("/.well-known/jwks.json", s.getJWKS)
+	s.App.Get("/export/download", s.downloadExport)
+	s.App.Get("/blobs/*", s.serveBlob)
+	s.App.Use(s.requireAuth)
+
+	// With EMAIL_VERIFICATION=writes unverified users can still sign out,
+	// change their password, correct a mistyped address, revoke tokens and
+	// delete the account. These routes are registered before
+	// requireVerifiedEmail; every other write goes through it.
+	s.App.Post("/logout-all", requireSession, s.logoutAll)
+	s.App.Delete("/sessions/:id", requireSession, s.deleteSession)
+	s.App.Post("/reset-password", requireSession, s.resetPassword)
+	s.App.Delete("/profile", requireSession, s.deleteAccount)
+	s.App.Post("/profile/email", requireSession, s.requestEmailChange)
+	s.App.Delete("/tokens/:id", requireSession, s.deleteToken)
+
+	s.App.Use(s.requireVerifiedEmail)
+
+	s.App.Get("/sessions", requireSession, s.getSessions)
+	s.App.Get("/profile", requireScope(models.ScopeProfileRead), s.getUserProfile)
+	s.App.Put("/profile", requireScope(models.ScopeProfileWrite), s.updateUserProfile)
+	s.App.Get("/profile/security-log", requireSession, s.getSecurityLog)
+	s.App.Post("/profile/avatar", requireScope(models.ScopeProfileWrite), s.uploadAvatar)
+	s.App.Delete("/profile/avatar", requireScope(models.ScopeProfileWrite), s.deleteAvatar)
+	s.App.Get("/settings", requireScope(models.ScopeProfileRead), s.getSettings)
+	s.App.Patch("/settings", requireScope(models.ScopeProfileWrite), s.updateSettings)
+
+	s.App.Post("/export", requireSession, s.requestExport)
+	s.App.Get("/export", requireSession, s.getExports)
+
+	s.App.Get("/2fa", requireSession, s.getTwoFactorStatus)
+	s.App.Post("/2fa/totp/setup", requireSession, s.setupTOTP)
+	s.App.Post("/2fa/totp/confirm", requireSession, s.confirmTOTP)
+	s.App.Post("/2fa/totp/disable", requireSession, s.disableTOTP)
+	s.App.Post("/2fa/recovery-codes", requireSession, s.regenerateRecoveryCodes)
+
+	s.App.Get("/tokens", requireSession, s.getTokens)
+	s.App.Post("/tokens", requireSession, s.createToken)
+
+	s.App.Get("/identities", requireSession, s.getIdentities)
+	s.App.Delete("/identities/:id", requireSession, s.deleteIdentity)
+
+	admin := s.App.Group("/admin", requireSession, requireRole(models.RoleAdmin))
+	admin.Use(pprof.New(pprof.Config{Prefix: "/admin"}))
+	admin.Get("/memory", s.getMemoryStats)
+	admin.Get("/users", s.getAdminUsers)
+	admin.Get("/users/:id", s.getAdminUser)
+	admin.Post("/users/:id/disable", forbidSelf, s.setUserDisabled(true))
+	admin.Post("/users/:id/enable", forbidSelf, s.setUserDisabled(false))
+	admin.Post("/users/:id/force-password-reset", forbidSelf, s.forcePasswordReset)
+	admin.Put("/users/:id/role", forbidSelf, s.setUserRole)
+	admin.Get("/audit-events", s.getAdminAuditEvents)
+
+	cardsRead, cardsWrite := requireScope(models.ScopeCardsRead), requireScope(models.ScopeCardsWrite)
+	s.App.Post("/cards", cardsWrite, s.createCard)
+	s.App.Get("/cards", cardsRead, s.getAllCards)
+	s.App.Get("/cards/pending", cardsRead, s.getPendingCards)
+	s.App.Get("/cards/overdue", cardsRead, s.getOverdueCards)
+	s.App.Get("/cards/due-today", cardsRead, s.getCardsDueToday)
+	s.App.Get("/cards/due-this-week", cardsRead, s.getCardsDueThisWeek)
+	s.App.Get("/cards/:id/reminders", cardsRead, s.getCardReminders)
+	s.App.Post("/cards/:id/reminders", cardsWrite, s.createCardReminder)
+	s.App.Delete("/cards/:id/reminders/:reminderId", cardsWrite, s.deleteCardReminder)
+	s.App.Get("/cards/:id<int />", cardsRead, s.getSingleCard)
+	s.App.Put("/cards/:id<int />", cardsWrite, s.updateCard)
+	s.App.Put("/cards/status/:id<int />", cardsWrite, s.updateCardColumn)
+	s.App.Put("/cards/:id/board", cardsWrite, s.moveCardToBoard)
+	s.App.Post("/cards/:id/move", cardsWrite, s.moveCard)
+	s.App.Put("/cards/:id/labels", cardsWrite, s.setLabels(repositories.LabelCards))
+	s.App.Post("/cards/:id/labels/:labelId", cardsWrite, s.addLabel(repositories.LabelCards))
+	s.App.Delete("/cards/:id/labels/:labelId", cardsWrite, s.removeLabel(repositories.LabelCards))
+	s.App.Delete("/cards/:id<int />", cardsWrite, s.deleteCard)
+
+	s.App.Get("/boards", cardsRead, s.getBoards)
+	s.App.Post("/boards", cardsWrite, s.createBoard)
+	s.App.Get("/boards/:id", cardsRead, s.getBoard)
+	s.App.Put("/boards/:id", cardsWrite, s.updateBoard)
+	s.App.Post("/boards/:id/archive", cardsWrite, s.archiveBoard)
+	s.App.Post("/boards/:id/unarchive", cardsWrite, s.unarchiveBoard)
+	s.App.Delete("/boards/:id", cardsWrite, s.deleteBoard)
+
+	s.App.Get("/columns", cardsRead, s.getColumns)
+	s.App.Post("/columns", cardsWrite, s.createColumn)
+	s.App.Put("/columns/order", cardsWrite, s.reorderColumns)
+	s.App.Put("/columns/:id", cardsWrite, s.updateColumn)
+	s.App.Delete("/columns/:id", cardsWrite, s.deleteColumn)
+
+	notesRead, notesWrite := requireScope(models.ScopeNotesRead), requireScope(models.ScopeNotesWrite)
+	s.App.Post("/notes", notesWrite, s.createNote)
+	s.App.Get("/notes", notesRead, s.getAllNotes)
+	s.App.Get("/notes/:id", notesRead, s.getSingleNote)
+	s.App.Put("/notes/:id", notesWrite, s.updateNote)
+	s.App.Delete("/notes/:id", notesWrite, s.deleteNote)
+	s.App.Put("/notes/:id/labels", notesWrite, s.setLabels(repositories.LabelNotes))
+	s.App.Post("/notes/:id/labels/:labelId", notesWrite, s.addLabel(repositories.LabelNotes))
+	s.App.Delete("/notes/:id/labels/:labelId", notesWrite, s.removeLabel(repositories.LabelNotes))
+
+	s.App.Get("/labels", requireScope(models.ScopeCardsRead, models.ScopeNotesRead), s.getLabels)
+	s.App.Post("/labels", cardsWrite, notesWrite, s.createLabel)
+	s.App.Put("/labels/:id", cardsWrite, notesWrite, s.updateLabel)
+	s.App.Delete("/labels/:id", cardsWrite, notesWrite, s.deleteLabel)
+
+	s.App.Post("/import", cardsWrite, notesWrite, s.importData)
+
+	s.App.Get(("/search"), requireScope(models.ScopeCardsRead, models.ScopeNotesRead), s.searchData)
+
+	s.App.Post("/gemini", requireScope(models.ScopeAI), s.geminiHandler)
 }
 
 func (s *FiberServer) healthHandler(c *fiber.Ctx) error {
@@ -70,44 +150,76 @@ func (s *FiberServer) login(c *fiber.Ctx) error {
 	if err := c.BodyParser(&credentials); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid json body"})
 	}
-	repo := repositories.NewUserRepository(s.db.DB())
-	user, err := repo.GetByEmail(c.Context(), credentials.Email)
+	wait, err := s.loginWait(c.Context(), c.IP(), credentials.Email)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "user not found"})
-	}
-	// Throws Unauthorized error
-	if !utils.CheckPasswordHash(credentials.Password, user.Password) {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Invalid credentials"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to log in"})
 	}
-
-	// Create the Claims
-	claims := jwt.MapClaims{
-		"email": user.Email,
-		"exp":   time.Now().Add(time.Hour * 72).Unix(),
+	if wait > 0 {
+		return tooManyAttempts(c, wait)
 	}
-
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+	repo := repositories.NewUserRepository(s.db.DB())
+	user, err := repo.GetByEmail(c.Context(), credentials.Email)
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		if err.Error() != "user not found" {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to log in"})
+		}
+		user = nil
+	}
+	// Unknown accounts and wrong passwords get the same answer so the
+	// endpoint cannot be used to find registered addresses.
+	if !checkCredentials(user, credentials.Password) {
+		s.recordLoginFailure(c.Context(), c.IP(), credentials.Email)
+		userID := uuid.Nil
+		if user != nil {
+			userID = user.ID
+		}
+		s.audit(c, models.AuditLoginFailed, userID, fiber.Map{"email": credentials.Email, "reason": "invalid_credentials"})
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid credentials"})
+	}
+	s.upgradePasswordHash(c.Context(), user, credentials.Password)
+	if user.DisabledAt != nil {
+		s.audit(c, models.AuditLoginFailed, user.ID, fiber.Map{"email": credentials.Email, "reason": "account_disabled"})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Account disabled"})
+	}
+	if user.PasswordResetRequired {
+		s.audit(c, models.AuditLoginFailed, user.ID, fiber.Map{"email": credentials.Email, "reason": "password_reset_required"})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Password reset required, check your email", "password_reset_required": true})
+	}
+	if emailVerificationMode == verificationLogin && user.EmailVerifiedAt == nil {
+		s.audit(c, models.AuditLoginFailed, user.ID, fiber.Map{"email": credentials.Email, "reason": "email_not_verified"})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Email address not verified"})
+	}
+	if user.TOTPEnabledAt != nil {
+		challenge, err := s.signChallengeToken(user)
+		if err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.JSON(fiber.Map{"mfa_required": true, "challenge_token": challenge})
 	}
 
-	return c.JSON(fiber.Map{"token": t})
+	return s.completeLogin(c, user, "password")
 }
 
 func (s *FiberServer) registerUser(c *fiber.Ctx) error {
-	user := models.User{}
-
-	if err := c.BodyParser(&user); err != nil {
+	var req dto.RegisterRequest
+	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid json body"})
 	}
-	var err error
-	user.Password, err = utils.HashPassword(user.Password)
+	errs, err := s.passwordErrors(c.Context(), "password", req.Password)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Error hashing password, max-length:64 characters"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create user"})
+	}
+	if len(errs) > 0 {
+		return invalidPassword(c, errs)
+	}
+	user := models.User{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     req.Email,
+	}
+	user.Password, err = utils.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create user"})
 	}
 	repo := repositories.NewUserRepository(s.db.DB())
 	err = repo.Create(c.Context(), &user)
@@ -115,22 +227,16 @@ func (s *FiberServer) registerUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotAcceptable).JSON(fiber.Map{"message": "User already exist"})
 
 	}
+	s.audit(c, models.AuditRegistered, user.ID, nil)
+	if err := s.sendVerificationEmail(c.Context(), &user); err != nil {
+		log.Printf("failed to send verification email: %v", err)
+	}
 	return c.JSON(fiber.Map{"message": "created user successfully"})
 
 }
 
 func (s *FiberServer) resetPassword(c *fiber.Ctx) error {
-	// Get user from JWT token
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-
-	// Get user from database
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	// Parse request body
 	var req dto.PasswordResetRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -141,8 +247,16 @@ func (s *FiberServer) resetPassword(c *fiber.Ctx) error {
 	if req.OldPassword == "" || req.NewPassword == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Old password and new password are required"})
 	}
+	errs, err := s.passwordErrors(c.Context(), "new_password", req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reset password"})
+	}
+	if len(errs) > 0 {
+		return invalidPassword(c, errs)
+	}
 
 	// Call repository to reset password
+	userRepo := repositories.NewUserRepository(s.db.DB())
 	err = userRepo.ResetPassword(c.Context(), currentUser.ID, req.OldPassword, req.NewPassword)
 	if err != nil {
 		if err.Error() == "user not found" {
@@ -153,41 +267,31 @@ func (s *FiberServer) resetPassword(c *fiber.Ctx) error {
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to reset password"})
 	}
+	s.audit(c, models.AuditPasswordChanged, currentUser.ID, nil)
 
 	return c.JSON(fiber.Map{"message": "Password reset successful"})
 }
 
 func (s *FiberServer) getUserProfile(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 
 	return c.JSON(fiber.Map{
-		"id":         currentUser.ID,
-		"email":      currentUser.Email,
-		"first_name": currentUser.FirstName,
-		"last_name":  currentUser.LastName,
-		"created_at": currentUser.CreatedAt,
-		"updated_at": currentUser.UpdatedAt,
+		"id":                    currentUser.ID,
+		"email":                 currentUser.Email,
+		"first_name":            currentUser.FirstName,
+		"last_name":             currentUser.LastName,
+		"created_at":            currentUser.CreatedAt,
+		"updated_at":            currentUser.UpdatedAt,
+		"deletion_scheduled_at": currentUser.DeletionScheduledAt,
+		"has_password":          currentUser.Password != "",
+		"role":                  currentUser.Role,
+		"avatar_url":            s.avatarURL(currentUser),
+		"avatar_urls":           s.avatarURLs(currentUser),
 	})
 }
 
 func (s *FiberServer) updateUserProfile(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 
 	var req dto.UpdateProfileRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -201,23 +305,27 @@ func (s *FiberServer) updateUserProfile(c *fiber.Ctx) error {
 	currentUser.FirstName = req.FirstName
 	currentUser.LastName = req.LastName
 
-	err = userRepo.Update(c.Context(), currentUser)
+	userRepo := repositories.NewUserRepository(s.db.DB())
+	err := userRepo.Update(c.Context(), currentUser)
 	if err != nil {
 		if err.Error() == "user not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update profile"})
 	}
+	s.audit(c, models.AuditProfileUpdated, currentUser.ID, fiber.Map{"first_name": currentUser.FirstName, "last_name": currentUser.LastName})
 
 	return c.JSON(fiber.Map{
 		"message": "Profile updated successfully",
 		"user": fiber.Map{
-			"id":         currentUser.ID,
-			"email":      currentUser.Email,
-			"first_name": currentUser.FirstName,
-			"last_name":  currentUser.LastName,
-			"created_at": currentUser.CreatedAt,
-			"updated_at": currentUser.UpdatedAt,
+			"id":          currentUser.ID,
+			"email":       currentUser.Email,
+			"first_name":  currentUser.FirstName,
+			"last_name":   currentUser.LastName,
+			"avatar_url":  s.avatarURL(currentUser),
+			"avatar_urls": s.avatarURLs(currentUser),
+			"created_at":  currentUser.CreatedAt,
+			"updated_at":  currentUser.UpdatedAt,
 		},
 	})
 }
@@ -225,36 +333,38 @@ func (s *FiberServer) updateUserProfile(c *fiber.Ctx) error {
 // Card endpoints
 
 func (s *FiberServer) createCard(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	cardRepo := repositories.NewCardRepository(s.db.DB())
 	card := models.Card{}
 	if err := c.BodyParser(&card); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid json"})
 
 	}
+	if card.ColumnID == uuid.Nil {
+		columnID, err := s.defaultColumn(c.Context(), currentUser.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to add card"})
+		}
+		card.ColumnID = columnID
+	}
+	if _, err := s.writableColumn(c.Context(), currentUser.ID, card.ColumnID); err != nil {
+		return columnError(c, err, "Failed to add card")
+	}
+	if errs := validateCardDates(&card); len(errs) > 0 {
+		return validationFailed(c, "Invalid card", errs)
+	}
 	card.UserID = currentUser.ID
 	if err := cardRepo.Create(c.Context(), &card); err != nil {
+		if errors.Is(err, repositories.ErrColumnNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Column not found"})
+		}
 		return c.Status(fiber.StatusNotAcceptable).JSON(fiber.Map{"message": "This Card already exists"})
 	}
 	return c.JSON(fiber.Map{"message": "Card added successfully"})
 }
 
 func (s *FiberServer) getSingleCard(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	cardRepo := repositories.NewCardRepository(s.db.DB())
 	uid, err := uuid.Parse(id)
@@ -270,48 +380,41 @@ func (s *FiberServer) getSingleCard(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) getAllCards(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
-	cardRepo := repositories.NewCardRepository(s.db.DB())
-	cards, err := cardRepo.GetAll(c.Context(), currentUser.ID)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch cards"})
-	}
-	return c.JSON(fiber.Map{"cards": cards})
+	return s.listCards(c, repositories.CardFilter{})
 }
 
 func (s *FiberServer) getPendingCards(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
+	return s.listCards(c, repositories.CardFilter{Pending: true})
+}
+
+// listCards lists the cards matching filter on the board given as board_id,
+// or on every board that is not archived.
+func (s *FiberServer) listCards(c *fiber.Ctx, filter repositories.CardFilter) error {
+	currentUser := userFromContext(c)
+	boardID, err := boardQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid board_id"})
+	}
+	if boardID != nil {
+		if _, err := repositories.NewBoardRepository(s.db.DB()).GetByID(c.Context(), *boardID, currentUser.ID); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Board not found"})
+		}
+	}
+	filter.Labels, err = labelQuery(c)
 	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
 	}
 	cardRepo := repositories.NewCardRepository(s.db.DB())
-	cards, err := cardRepo.GetPending(c.Context(), currentUser.ID)
+	filter.BoardID = boardID
+	cards, err := cardRepo.List(c.Context(), currentUser.ID, filter)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "unable to fetch cards"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch cards"})
 	}
 	return c.JSON(fiber.Map{"cards": cards})
 }
 
 func (s *FiberServer) updateCard(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	cardRepo := repositories.NewCardRepository(s.db.DB())
 	var card = models.Card{}
@@ -320,10 +423,23 @@ func (s *FiberServer) updateCard(c *fiber.Ctx) error {
 			"error": "invalid request body",
 		})
 	}
+	var err error
 	card.ID, err = uuid.Parse(id)
 	if err != nil {
 		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"message": "invalid uid"})
 	}
+	if card.ColumnID == uuid.Nil {
+		current, err := cardRepo.GetByID(c.Context(), card.ID, currentUser.ID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Card not found"})
+		}
+		card.ColumnID = current.ColumnID
+	} else if _, err := s.writableColumn(c.Context(), currentUser.ID, card.ColumnID); err != nil {
+		return columnError(c, err, "Failed to update card")
+	}
+	if errs := validateCardDates(&card); len(errs) > 0 {
+		return validationFailed(c, "Invalid card", errs)
+	}
 	err = cardRepo.Update(c.Context(), &card, currentUser.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -336,19 +452,13 @@ func (s *FiberServer) updateCard(c *fiber.Ctx) error {
 	})
 }
 
-func (s *FiberServer) updateCardStatus(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+// updateCardColumn moves a card to another column.
+func (s *FiberServer) updateCardColumn(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	cardRepo := repositories.NewCardRepository(s.db.DB())
-	var status dto.CardStatus
-	if err := c.BodyParser(&status); err != nil {
+	var column dto.CardColumn
+	if err := c.BodyParser(&column); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "invalid request body",
 		})
@@ -357,7 +467,10 @@ func (s *FiberServer) updateCardStatus(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"message": "invalid uid"})
 	}
-	err = cardRepo.UpdateStatus(c.Context(), uid, status.Status, currentUser.ID)
+	if _, err := s.writableColumn(c.Context(), currentUser.ID, column.ColumnID); err != nil {
+		return columnError(c, err, "Failed to update card")
+	}
+	err = cardRepo.MoveToColumn(c.Context(), uid, column.ColumnID, currentUser.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -368,15 +481,57 @@ func (s *FiberServer) updateCardStatus(c *fiber.Ctx) error {
 	})
 }
 
-func (s *FiberServer) deleteCard(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
+// moveCard puts a card between previous_id and next_id in column_id, which
+// defaults to the column of the card. Either neighbor may be left out to
+// move the card to the start or end of the column.
+func (s *FiberServer) moveCard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	uid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	var move dto.CardMove
+	if err := c.BodyParser(&move); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	card, err := repositories.NewCardRepository(s.db.DB()).GetByID(c.Context(), uid, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Card not found"})
+	}
+	columnID := card.ColumnID
+	if move.ColumnID != nil {
+		columnID = *move.ColumnID
+	}
+	if _, err := s.writableColumn(c.Context(), currentUser.ID, columnID); err != nil {
+		return columnError(c, err, "Failed to move card")
+	}
+
+	// A rebalanced column is only kept together with the move.
+	tx, err := s.db.DB().BeginTx(c.Context(), nil)
 	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
 	}
+	defer tx.Rollback()
+	cardRepo := repositories.NewCardRepository(tx)
+	err = cardRepo.Move(c.Context(), card.ID, columnID, currentUser.ID, move.PreviousID, move.NextID)
+	if errors.Is(err, repositories.ErrInvalidNeighbors) {
+		return validationFailed(c, "Invalid move", []fieldError{{Field: "previous_id", Code: "invalid", Message: "Neighbors must be other cards of the column, previous_id before next_id"}})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	card, err = cardRepo.GetByID(c.Context(), card.ID, currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to move card"})
+	}
+	return c.JSON(fiber.Map{"message": "Card moved successfully", "card": card})
+}
+
+func (s *FiberServer) deleteCard(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -398,14 +553,7 @@ func (s *FiberServer) deleteCard(c *fiber.Ctx) error {
 // Notes endpoints
 
 func (s *FiberServer) createNote(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	noteRepo := repositories.NewNoteRepository(s.db.DB())
 	note := models.Note{}
 	if err := c.BodyParser(&note); err != nil {
@@ -421,14 +569,7 @@ func (s *FiberServer) createNote(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) getSingleNote(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	noteRepo := repositories.NewNoteRepository(s.db.DB())
 	uid, err := uuid.Parse(id)
@@ -443,17 +584,13 @@ func (s *FiberServer) getSingleNote(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) getAllNotes(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
+	currentUser := userFromContext(c)
+	labels, err := labelQuery(c)
 	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
 	}
-	limit := c.QueryInt("limit")
 	noteRepo := repositories.NewNoteRepository(s.db.DB())
-	notes, err := noteRepo.GetAll(c.Context(), currentUser.ID, limit)
+	notes, err := noteRepo.List(c.Context(), currentUser.ID, repositories.NoteFilter{Limit: c.QueryInt("limit"), Labels: labels})
 	if err != nil {
 		c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch user notes"})
 	}
@@ -461,14 +598,7 @@ func (s *FiberServer) getAllNotes(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) updateNote(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	noteRepo := repositories.NewNoteRepository(s.db.DB())
 	var note = models.Note{}
@@ -497,14 +627,7 @@ func (s *FiberServer) updateNote(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) deleteNote(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
-	}
+	currentUser := userFromContext(c)
 	id := c.Params("id")
 	uid, err := uuid.Parse(id)
 	if err != nil {
@@ -524,16 +647,13 @@ func (s *FiberServer) deleteNote(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) searchData(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	currentUser, err := userRepo.GetByEmail(c.Context(), email)
+	currentUser := userFromContext(c)
+	boardID, err := boardQuery(c)
 	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid board_id"})
 	}
 	searchRepo := repositories.NewSearchRepository(s.db.DB())
-	data, err := searchRepo.SearchQuery(c.Context(), c.Query("q"), currentUser.ID)
+	data, err := searchRepo.SearchQuery(c.Context(), c.Query("q"), currentUser.ID, boardID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch cards"})
 	}
@@ -541,13 +661,8 @@ func (s *FiberServer) searchData(c *fiber.Ctx) error {
 }
 
 func (s *FiberServer) geminiHandler(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	email := claims["email"].(string)
-	userRepo := repositories.NewUserRepository(s.db.DB())
-	_, err := userRepo.GetByEmail(c.Context(), email)
-	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid user"})
+	if !s.userSettings(c.Context(), userFromContext(c).ID).AIEnabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "AI features are turned off in your settings"})
 	}
 	var req dto.GeminiRequest
 	if err := c.BodyParser(&req); err != nil {
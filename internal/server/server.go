@@ -4,13 +4,20 @@ import (
 	"context"
 	"log"
 	"os"
+	"rytr/internal/blobstore"
 	"rytr/internal/database"
+	"rytr/internal/keys"
+	"rytr/internal/lockout"
+	"rytr/internal/mailer"
+	"rytr/internal/notify"
+	"rytr/internal/oidc"
+	"rytr/internal/password"
+	"rytr/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/favicon"
 	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/pprof"
 	"google.golang.org/genai"
 )
 
@@ -19,6 +26,21 @@ type FiberServer struct {
 
 	db           database.Service
 	geminiClient *genai.Client
+	mailer       mailer.Mailer
+	notifier     notify.Notifier
+	keys         *keys.KeySet
+	blobs        blobstore.BlobStore
+
+	passwordPolicy *password.Policy
+
+	oidcProviders map[string]*oidc.Provider
+
+	loginAttemptStore lockout.Store
+	ipLimiter         *lockout.Limiter
+	accountLimiter    *lockout.Limiter
+
+	mailIPLimiter      *lockout.Limiter
+	mailAccountLimiter *lockout.Limiter
 }
 
 func New() *FiberServer {
@@ -29,6 +51,9 @@ func New() *FiberServer {
 		}),
 		db: database.New(),
 	}
+	if err := utils.LoadSecretKey(); err != nil {
+		log.Fatalf("Failed to load secret key: %v", err)
+	}
 	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
 		APIKey:  os.Getenv("GEMINI_API_KEY"),
 		Backend: genai.BackendGeminiAPI,
@@ -37,17 +62,45 @@ func New() *FiberServer {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 	server.geminiClient = client
+	server.mailer, err = mailer.New()
+	if err != nil {
+		log.Fatalf("Failed to create mailer: %v", err)
+	}
+	server.notifier, err = notify.New(server.mailer)
+	if err != nil {
+		log.Fatalf("Failed to create notifier: %v", err)
+	}
+	server.blobs, err = blobstore.New(apiBaseURL + "/blobs")
+	if err != nil {
+		log.Fatalf("Failed to create blob store: %v", err)
+	}
+	server.passwordPolicy, err = password.LoadPolicy()
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	server.ipLimiter, server.accountLimiter = server.newLoginLimiters()
+	server.mailIPLimiter, server.mailAccountLimiter = server.newMailLimiters()
+	if dir := os.Getenv("JWT_KEY_DIR"); dir != "" {
+		server.keys, err = keys.LoadDir(dir, os.Getenv("JWT_SIGNING_KEY_ID"))
+	} else {
+		log.Println("JWT_KEY_DIR is not set, signing tokens with a temporary key")
+		server.keys, err = keys.Generate()
+	}
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	server.oidcProviders, err = oidc.LoadProviders()
+	if err != nil {
+		log.Fatalf("Failed to configure identity providers: %v", err)
+	}
 	server.App.Use(favicon.New())
 	server.App.Use(cors.New(cors.Config{
 		AllowOrigins: "http://localhost:5173, https://rytr.fuzzydevs.com, https://rytr.therishabhdev.com", // Your React app's URL
 		AllowHeaders: "Origin, Content-Type, Accept, Authorization,X-Requested-With",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		// Optional: Enable preflight request caching
 		MaxAge: 3600,
 	}))
 	server.App.Use(logger.New())
-	server.App.Use(pprof.New(pprof.Config{
-		Next: nil, // Use this if you want to exclude specific routes
-	}))
 	return server
 }
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"rytr/internal/keys"
+	"rytr/internal/mailer"
+	"rytr/internal/password"
+	"rytr/internal/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// testDatabase is a database.Service over the test container.
+type testDatabase struct {
+	db *sql.DB
+}
+
+func (d *testDatabase) Health() map[string]string { return map[string]string{"status": "up"} }
+func (d *testDatabase) Close() error              { return d.db.Close() }
+func (d *testDatabase) DB() *sql.DB               { return d.db }
+
+var (
+	testServerOnce sync.Once
+	testServer     *FiberServer
+	testServerErr  error
+)
+
+// newTestServer returns a server with every route registered, backed by a
+// migrated Postgres container shared by the tests of the package. Tests
+// calling it are skipped when Docker is not available.
+func newTestServer(t *testing.T) *FiberServer {
+	t.Helper()
+	testServerOnce.Do(func() {
+		testServer, testServerErr = startTestServer()
+	})
+	if testServerErr != nil {
+		t.Skipf("could not start test server: %v", testServerErr)
+	}
+	return testServer
+}
+
+func startTestServer() (s *FiberServer, err error) {
+	// testcontainers panics rather than failing when there is no Docker.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	ctx := context.Background()
+	container, err := postgres.Run(
+		ctx,
+		"postgres:latest",
+		postgres.WithDatabase("rytr"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	connStr, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.New("file://../database/migrations", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return nil, err
+	}
+	db, err := sql.Open("pgx", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Getenv("SECRET_KEY") == "" {
+		os.Setenv("SECRET_KEY", strings.Repeat("s", utils.MinSecretKeyLength))
+	}
+	if err := utils.LoadSecretKey(); err != nil {
+		return nil, err
+	}
+	s = &FiberServer{
+		App:    fiber.New(),
+		db:     &testDatabase{db: db},
+		mailer: mailer.NewMemoryMailer(),
+	}
+	if s.keys, err = keys.Generate(); err != nil {
+		return nil, err
+	}
+	if s.passwordPolicy, err = password.LoadPolicy(); err != nil {
+		return nil, err
+	}
+	s.ipLimiter, s.accountLimiter = s.newLoginLimiters()
+	s.mailIPLimiter, s.mailAccountLimiter = s.newMailLimiters()
+	s.RegisterFiberRoutes()
+	return s, nil
+}
+
+// call sends a JSON request, authenticated with token unless it is empty,
+// and decodes the JSON response.
+func call(t *testing.T, s *FiberServer, method, path, token string, body any) (int, fiber.Map) {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var out fiber.Map
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+const testPassword = "correct horse battery staple"
+
+// registerAndLogin creates a new user and returns its email and the tokens
+// of a fresh session.
+func registerAndLogin(t *testing.T, s *FiberServer) (email, accessToken, refreshToken string) {
+	t.Helper()
+	email = "user-" + uuid.NewString() + "@example.com"
+	status, body := call(t, s, http.MethodPost, "/register", "", fiber.Map{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      email,
+		"password":   testPassword,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("register: status %d, body %v", status, body)
+	}
+	status, body = call(t, s, http.MethodPost, "/login", "", fiber.Map{"email": email, "password": testPassword})
+	if status != http.StatusOK {
+		t.Fatalf("login: status %d, body %v", status, body)
+	}
+	accessToken, _ = body["token"].(string)
+	refreshToken, _ = body["refresh_token"].(string)
+	if accessToken == "" || refreshToken == "" {
+		t.Fatalf("login: missing tokens in %v", body)
+	}
+	return email, accessToken, refreshToken
+}
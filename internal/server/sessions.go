@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const sessionLocalsKey = "sessionID"
+
+var (
+	// sessionTouchInterval limits how often requests update last_seen_at.
+	sessionTouchInterval = time.Minute
+	// Ended sessions are kept for a while so that replayed refresh tokens
+	// are still recognised as reuse.
+	sessionRetention = 7 * 24 * time.Hour
+)
+
+// createSession records a new login from the device making the request.
+func (s *FiberServer) createSession(c *fiber.Ctx, user *models.User) (*models.Session, error) {
+	session := &models.Session{
+		UserID:    user.ID,
+		UserAgent: userAgent(c),
+		IP:        c.IP(),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	repo := repositories.NewSessionRepository(s.db.DB())
+	if err := repo.Create(c.Context(), session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// checkSession reports whether the session an access token was issued for
+// is still active and records that it was used.
+func (s *FiberServer) checkSession(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	repo := repositories.NewSessionRepository(s.db.DB())
+	session, err := repo.GetActive(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrSessionNotFound) {
+			return errUnauthorized
+		}
+		return err
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := repo.Touch(ctx, id); err != nil {
+			log.Printf("failed to update session: %v", err)
+		}
+	}
+	return nil
+}
+
+// revokeSession ends a session: its access tokens stop working immediately
+// and its refresh tokens can no longer be used.
+func (s *FiberServer) revokeSession(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	repo := repositories.NewSessionRepository(s.db.DB())
+	// Revoking the session first checks that it belongs to the user.
+	if err := repo.Revoke(ctx, id, userID); err != nil {
+		return err
+	}
+	refreshRepo := repositories.NewRefreshTokenRepository(s.db.DB())
+	return refreshRepo.RevokeFamily(ctx, id)
+}
+
+// endSession revokes the session a refresh token was issued for, whether or
+// not it is still active.
+func (s *FiberServer) endSession(ctx context.Context, token *models.RefreshToken) error {
+	refreshRepo := repositories.NewRefreshTokenRepository(s.db.DB())
+	if err := refreshRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	repo := repositories.NewSessionRepository(s.db.DB())
+	err := repo.Revoke(ctx, token.FamilyID, token.UserID)
+	if errors.Is(err, repositories.ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
+
+// revokeAllSessions signs the user out everywhere: it ends every session and
+// deletes every personal access token.
+func (s *FiberServer) revokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	refreshRepo := repositories.NewRefreshTokenRepository(s.db.DB())
+	if err := refreshRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	repo := repositories.NewSessionRepository(s.db.DB())
+	if err := repo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	tokenRepo := repositories.NewPersonalAccessTokenRepository(s.db.DB())
+	return tokenRepo.RevokeAllForUser(ctx, userID)
+}
+
+func (s *FiberServer) pruneSessions(ctx context.Context) error {
+	repo := repositories.NewSessionRepository(s.db.DB())
+	_, err := repo.Prune(ctx, time.Now().Add(-sessionRetention))
+	return err
+}
+
+// sessionIDFromContext returns the session the request was authenticated
+// with, or uuid.Nil for personal access tokens.
+func sessionIDFromContext(c *fiber.Ctx) uuid.UUID {
+	id, _ := c.Locals(sessionLocalsKey).(uuid.UUID)
+	return id
+}
+
+func userAgent(c *fiber.Ctx) string {
+	ua := c.Get(fiber.HeaderUserAgent)
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	return ua
+}
+
+func (s *FiberServer) getSessions(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewSessionRepository(s.db.DB())
+	sessions, err := repo.GetAllActive(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch sessions"})
+	}
+	current := sessionIDFromContext(c)
+	result := make([]fiber.Map, 0, len(*sessions))
+	for _, session := range *sessions {
+		result = append(result, fiber.Map{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == current,
+		})
+	}
+	return c.JSON(fiber.Map{"sessions": result})
+}
+
+func (s *FiberServer) deleteSession(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	if err := s.revokeSession(c.Context(), id, currentUser.ID); err != nil {
+		if errors.Is(err, repositories.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Session not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke session"})
+	}
+	s.audit(c, models.AuditSessionRevoked, currentUser.ID, fiber.Map{"session_id": id})
+	return c.JSON(fiber.Map{"message": "Session revoked"})
+}
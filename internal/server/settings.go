@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"sort"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// settingFields validates and applies the value of every setting a client
+// may change.
+var settingFields = map[string]func(s *models.UserSettings, raw json.RawMessage) *fieldError{
+	"timezone": func(s *models.UserSettings, raw json.RawMessage) *fieldError {
+		var tz string
+		if json.Unmarshal(raw, &tz) != nil || tz == "" || tz == "Local" {
+			return &fieldError{Code: "invalid", Message: "Timezone must be an IANA time zone such as Europe/Berlin"}
+		}
+		if _, err := time.LoadLocation(tz); err != nil {
+			return &fieldError{Code: "invalid", Message: "Timezone must be an IANA time zone such as Europe/Berlin"}
+		}
+		s.Timezone = tz
+		return nil
+	},
+	// The column is checked to belong to the user by updateSettings.
+	"default_column_id": func(s *models.UserSettings, raw json.RawMessage) *fieldError {
+		var id *uuid.UUID
+		if json.Unmarshal(raw, &id) != nil || (id != nil && *id == uuid.Nil) {
+			return &fieldError{Code: "invalid", Message: "Default column must be the ID of a column or null"}
+		}
+		s.DefaultColumnID = id
+		return nil
+	},
+	"theme": stringSetting(func(s *models.UserSettings) *string { return &s.Theme },
+		models.ThemeSystem, models.ThemeLight, models.ThemeDark),
+	"week_start": stringSetting(func(s *models.UserSettings) *string { return &s.WeekStart },
+		models.WeekStartMonday, models.WeekStartSunday),
+	"ai_enabled": func(s *models.UserSettings, raw json.RawMessage) *fieldError {
+		var enabled bool
+		if json.Unmarshal(raw, &enabled) != nil {
+			return &fieldError{Code: "invalid", Message: "AI enabled must be true or false"}
+		}
+		s.AIEnabled = enabled
+		return nil
+	},
+}
+
+// stringSetting accepts one of allowed for the setting returned by field.
+func stringSetting(field func(s *models.UserSettings) *string, allowed ...string) func(s *models.UserSettings, raw json.RawMessage) *fieldError {
+	return func(s *models.UserSettings, raw json.RawMessage) *fieldError {
+		var value string
+		if json.Unmarshal(raw, &value) == nil {
+			for _, a := range allowed {
+				if value == a {
+					*field(s) = value
+					return nil
+				}
+			}
+		}
+		return &fieldError{Code: "invalid", Message: fmt.Sprintf("Value must be one of %q", allowed)}
+	}
+}
+
+// userSettings returns the settings of a user for features that depend on
+// them, falling back to the defaults if they cannot be read.
+func (s *FiberServer) userSettings(ctx context.Context, userID uuid.UUID) *models.UserSettings {
+	repo := repositories.NewUserSettingsRepository(s.db.DB())
+	settings, err := repo.Get(ctx, userID)
+	if err != nil {
+		log.Printf("failed to get settings of user %s: %v", userID, err)
+		defaults := models.DefaultUserSettings()
+		return &defaults
+	}
+	return settings
+}
+
+func (s *FiberServer) getSettings(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewUserSettingsRepository(s.db.DB())
+	settings, err := repo.Get(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch settings"})
+	}
+	return c.JSON(fiber.Map{"settings": settings})
+}
+
+// updateSettings changes the settings present in the body and keeps the
+// others. Nothing is changed if any value is invalid.
+func (s *FiberServer) updateSettings(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &patch); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request body"})
+	}
+	repo := repositories.NewUserSettingsRepository(s.db.DB())
+	settings, err := repo.Get(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update settings"})
+	}
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []fieldError
+	for _, key := range keys {
+		apply, ok := settingFields[key]
+		if !ok {
+			errs = append(errs, fieldError{Field: key, Code: "unknown", Message: "Unknown setting"})
+			continue
+		}
+		if e := apply(settings, patch[key]); e != nil {
+			e.Field = key
+			errs = append(errs, *e)
+		}
+	}
+	if _, ok := patch["default_column_id"]; ok && settings.DefaultColumnID != nil {
+		columnRepo := repositories.NewBoardColumnRepository(s.db.DB())
+		if _, err := columnRepo.GetByID(c.Context(), *settings.DefaultColumnID, currentUser.ID); err != nil {
+			errs = append(errs, fieldError{Field: "default_column_id", Code: "invalid", Message: "Default column must be the ID of a column or null"})
+		}
+	}
+	if len(errs) > 0 {
+		return validationFailed(c, "Invalid settings", errs)
+	}
+
+	if err := repo.Save(c.Context(), currentUser.ID, settings); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to update settings"})
+	}
+	s.audit(c, models.AuditSettingsUpdated, currentUser.ID, fiber.Map{"keys": keys})
+	return c.JSON(fiber.Map{"message": "Settings updated successfully", "settings": settings})
+}
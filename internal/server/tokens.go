@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"rytr/internal/database/dto"
+	"rytr/internal/database/models"
+	"rytr/internal/database/repositories"
+	"rytr/internal/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// personalAccessTokenPrefix marks personal access tokens so the auth
+// middleware can tell them apart from JWTs without parsing.
+const personalAccessTokenPrefix = "rytr_pat_"
+
+// requireScope restricts personal access tokens to routes covered by their
+// scopes. Interactive sessions may use every route.
+func requireScope(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := personalAccessTokenFromContext(c)
+		if token == nil {
+			return c.Next()
+		}
+		for _, scope := range scopes {
+			if !token.HasScope(scope) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Access token is missing the " + scope + " scope"})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// requireSession keeps personal access tokens away from routes that manage
+// credentials, such as creating more tokens or changing the password.
+func requireSession(c *fiber.Ctx) error {
+	if personalAccessTokenFromContext(c) != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "This endpoint cannot be used with an access token"})
+	}
+	return c.Next()
+}
+
+func (s *FiberServer) getTokens(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	repo := repositories.NewPersonalAccessTokenRepository(s.db.DB())
+	tokens, err := repo.GetAll(c.Context(), currentUser.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Unable to fetch tokens"})
+	}
+	return c.JSON(fiber.Map{"tokens": tokens})
+}
+
+func (s *FiberServer) createToken(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	var req dto.CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid json body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "name is required and must be at most 100 characters"})
+	}
+	if len(req.Scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "At least one scope is required", "scopes": models.Scopes})
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Unknown scope " + scope, "scopes": models.Scopes})
+		}
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "expires_at must be in the future"})
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create token"})
+	}
+	plain := personalAccessTokenPrefix + secret
+	slices.Sort(req.Scopes)
+	token := models.PersonalAccessToken{
+		UserID:    currentUser.ID,
+		Name:      req.Name,
+		TokenHash: utils.HashToken(plain),
+		Prefix:    plain[:len(personalAccessTokenPrefix)+4],
+		Scopes:    slices.Compact(req.Scopes),
+		ExpiresAt: req.ExpiresAt,
+	}
+	repo := repositories.NewPersonalAccessTokenRepository(s.db.DB())
+	if err := repo.Create(c.Context(), &token); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create token"})
+	}
+	s.audit(c, models.AuditTokenCreated, currentUser.ID, fiber.Map{"token_id": token.ID, "name": token.Name, "scopes": token.Scopes})
+	// The plaintext token is only ever returned here.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"token": plain, "details": token})
+}
+
+func (s *FiberServer) deleteToken(c *fiber.Ctx) error {
+	currentUser := userFromContext(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid uid"})
+	}
+	repo := repositories.NewPersonalAccessTokenRepository(s.db.DB())
+	if err := repo.Delete(c.Context(), id, currentUser.ID); err != nil {
+		if errors.Is(err, repositories.ErrPersonalAccessTokenNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Token not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to revoke token"})
+	}
+	s.audit(c, models.AuditTokenRevoked, currentUser.ID, fiber.Map{"token_id": id})
+	return c.JSON(fiber.Map{"message": "Token revoked"})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rytr/internal/database/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  *models.PersonalAccessToken
+		path   string
+		status int
+	}{
+		{"session reads", nil, "/read", http.StatusOK},
+		{"session writes", nil, "/write", http.StatusOK},
+		{"token with scope", &models.PersonalAccessToken{Scopes: []string{models.ScopeCardsRead}}, "/read", http.StatusOK},
+		{"token without scope", &models.PersonalAccessToken{Scopes: []string{models.ScopeCardsRead}}, "/write", http.StatusForbidden},
+		{"token on session route", &models.PersonalAccessToken{Scopes: models.Scopes}, "/session", http.StatusForbidden},
+		{"session on session route", nil, "/session", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals(userLocalsKey, &models.User{Role: models.RoleUser})
+				if tt.token != nil {
+					c.Locals(tokenLocalsKey, tt.token)
+				}
+				return c.Next()
+			})
+			ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+			app.Get("/read", requireScope(models.ScopeCardsRead), ok)
+			app.Get("/write", requireScope(models.ScopeCardsWrite), ok)
+			app.Get("/session", requireSession, ok)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	_, access, _ := registerAndLogin(t, s)
+
+	status, body := call(t, s, http.MethodPost, "/tokens", access, fiber.Map{
+		"name":   "read only",
+		"scopes": []string{models.ScopeCardsRead},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create token: status %d, body %v", status, body)
+	}
+	pat, _ := body["token"].(string)
+
+	if status, body = call(t, s, http.MethodGet, "/cards", pat, nil); status != http.StatusOK {
+		t.Errorf("GET /cards: status %d, body %v, want 200", status, body)
+	}
+	if status, body = call(t, s, http.MethodPost, "/cards", pat, fiber.Map{"title": "Nope"}); status != http.StatusForbidden {
+		t.Errorf("POST /cards: status %d, body %v, want 403", status, body)
+	}
+	for _, path := range []string{"/sessions", "/tokens"} {
+		if status, body = call(t, s, http.MethodGet, path, pat, nil); status != http.StatusForbidden {
+			t.Errorf("GET %s: status %d, body %v, want 403", path, status, body)
+		}
+	}
+	if status, body = call(t, s, http.MethodPost, "/tokens", pat, fiber.Map{"name": "more", "scopes": models.Scopes}); status != http.StatusForbidden {
+		t.Errorf("POST /tokens: status %d, body %v, want 403", status, body)
+	}
+}
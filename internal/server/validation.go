@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fieldError explains why the value of one request field was refused.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func validationFailed(c *fiber.Ctx, message string, errs []fieldError) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": message, "errors": errs})
+}
+
+// passwordErrors checks a new password against the password policy and
+// reports every broken rule as an error of field.
+func (s *FiberServer) passwordErrors(ctx context.Context, field, pw string) ([]fieldError, error) {
+	violations, err := s.passwordPolicy.Validate(ctx, pw)
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]fieldError, 0, len(violations))
+	for _, v := range violations {
+		errs = append(errs, fieldError{Field: field, Code: v.Code, Message: v.Message})
+	}
+	return errs, nil
+}
+
+// invalidPassword answers a request whose new password breaks the policy.
+func invalidPassword(c *fiber.Ctx, errs []fieldError) error {
+	return validationFailed(c, "Password does not meet the requirements", errs)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnvDuration reads a time.Duration (e.g. "15m") from the environment and
+// falls back to def when the variable is unset or invalid.
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetEnvInt reads an integer from the environment and falls back to def when
+// the variable is unset or invalid.
+func GetEnvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetEnvBool reads a boolean from the environment and falls back to def when
+// the variable is unset or invalid.
+func GetEnvBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// GetEnv reads a string from the environment and falls back to def when the
+// variable is unset.
+func GetEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
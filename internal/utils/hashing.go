@@ -1,13 +1,178 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher creates and checks one kind of password hash.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) bool
+	// NeedsRehash reports whether encoded was created with other settings
+	// than the hasher's current ones.
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher stores hashes in the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// BcryptHasher is only kept so passwords hashed before the switch to
+// Argon2id keep working until they are rehashed.
+type BcryptHasher struct {
+	Cost int
+}
+
+var errInvalidHash = errors.New("invalid password hash")
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password, encoded string) bool {
+	parsed, err := parseArgon2id(encoded)
+	if err != nil || parsed.version != argon2.Version {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.iterations, parsed.memory, parsed.parallelism, uint32(len(parsed.key)))
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	parsed, err := parseArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return parsed.version != argon2.Version ||
+		parsed.memory != h.Memory ||
+		parsed.iterations != h.Iterations ||
+		parsed.parallelism != h.Parallelism ||
+		uint32(len(parsed.salt)) != h.SaltLength ||
+		uint32(len(parsed.key)) != h.KeyLength
+}
+
+func parseArgon2id(encoded string) (*argon2idHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, errInvalidHash
+	}
+	h := argon2idHash{}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &h.version); err != nil {
+		return nil, errInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism); err != nil {
+		return nil, errInvalidHash
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errInvalidHash
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return nil, errInvalidHash
+	}
+	return &h, nil
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h BcryptHasher) Verify(password, encoded string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+var (
+	passwordHasherOnce sync.Once
+	passwordHasher     PasswordHasher
+)
+
+// currentPasswordHasher returns the hasher for new passwords, selected with
+// PASSWORD_HASH_ALGORITHM. It is created on first use so that .env files are
+// loaded by then.
+func currentPasswordHasher() PasswordHasher {
+	passwordHasherOnce.Do(func() {
+		if strings.ToLower(GetEnv("PASSWORD_HASH_ALGORITHM", "argon2id")) == "bcrypt" {
+			passwordHasher = BcryptHasher{Cost: GetEnvInt("BCRYPT_COST", 12)}
+			return
+		}
+		passwordHasher = Argon2idHasher{
+			Memory:      uint32(GetEnvInt("ARGON2_MEMORY", 64*1024)),
+			Iterations:  uint32(GetEnvInt("ARGON2_ITERATIONS", 3)),
+			Parallelism: uint8(GetEnvInt("ARGON2_PARALLELISM", 2)),
+			SaltLength:  16,
+			KeyLength:   32,
+		}
+	})
+	return passwordHasher
+}
+
+// hasherFor picks the hasher that created encoded.
+func hasherFor(encoded string) PasswordHasher {
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		if h, ok := currentPasswordHasher().(Argon2idHasher); ok {
+			return h
+		}
+		return Argon2idHasher{}
+	}
+	if h, ok := currentPasswordHasher().(BcryptHasher); ok {
+		return h
+	}
+	return BcryptHasher{}
+}
 
 func HashPassword(password string) (string, error) {
-    bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-    return string(bytes), err
+	return currentPasswordHasher().Hash(password)
 }
 
 func CheckPasswordHash(password, hash string) bool {
-    err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-    return err == nil
+	return hasherFor(hash).Verify(password, hash)
+}
+
+// PasswordNeedsRehash reports whether hash should be replaced by a fresh
+// HashPassword result, because it uses another algorithm or older settings.
+func PasswordNeedsRehash(hash string) bool {
+	current := currentPasswordHasher()
+	if hasherFor(hash) != current {
+		return true
+	}
+	return current.NeedsRehash(hash)
 }
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2id = Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasherRoundTrip(t *testing.T) {
+	hash, err := testArgon2id.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash format: %s", hash)
+	}
+	if !testArgon2id.Verify("correct horse battery staple", hash) {
+		t.Errorf("expected password to verify")
+	}
+	if testArgon2id.Verify("correct horse battery stapler", hash) {
+		t.Errorf("expected wrong password to be rejected")
+	}
+	if testArgon2id.NeedsRehash(hash) {
+		t.Errorf("expected hash with current parameters not to need a rehash")
+	}
+
+	stronger := testArgon2id
+	stronger.Iterations = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Errorf("expected hash with older parameters to need a rehash")
+	}
+	// Hashes keep verifying after the parameters change.
+	if !stronger.Verify("correct horse battery staple", hash) {
+		t.Errorf("expected hash with older parameters to verify")
+	}
+}
+
+func TestArgon2idHasherRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+	} {
+		if testArgon2id.Verify("password", hash) {
+			t.Errorf("expected %q to be rejected", hash)
+		}
+	}
+}
+
+func TestLegacyBcryptHashes(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("error creating bcrypt hash: %v", err)
+	}
+	if !CheckPasswordHash("password", string(legacy)) {
+		t.Errorf("expected bcrypt hash to verify")
+	}
+	if CheckPasswordHash("other", string(legacy)) {
+		t.Errorf("expected wrong password to be rejected")
+	}
+	if !PasswordNeedsRehash(string(legacy)) {
+		t.Errorf("expected bcrypt hash to need a rehash")
+	}
+
+	hash, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword returned error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") || !CheckPasswordHash("password", hash) {
+		t.Errorf("expected a verifiable argon2id hash, got %s", hash)
+	}
+	if PasswordNeedsRehash(hash) {
+		t.Errorf("expected fresh hash not to need a rehash")
+	}
+}
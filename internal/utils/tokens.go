@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MinSecretKeyLength is the shortest SECRET_KEY accepted, in bytes.
+const MinSecretKeyLength = 32
+
+var secretKey []byte
+
+// LoadSecretKey reads SECRET_KEY, the key of the signatures made by
+// NewSignedToken and SignValue. It is called once at startup, before any
+// token is signed or verified.
+func LoadSecretKey() error {
+	key := os.Getenv("SECRET_KEY")
+	if len(key) < MinSecretKeyLength {
+		return fmt.Errorf("SECRET_KEY must be at least %d bytes long", MinSecretKeyLength)
+	}
+	secretKey = []byte(key)
+	return nil
+}
+
+// GenerateRandomToken returns a URL-safe random string built from n bytes of
+// entropy.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token. Only the hash
+// is stored so a database leak does not leak usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSignedToken returns a random token bound to purpose by an HMAC signature
+// keyed with SECRET_KEY, so forged or mistyped tokens can be rejected without
+// a database lookup.
+func NewSignedToken(purpose string) (string, error) {
+	random, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return random + "." + signToken(purpose, random), nil
+}
+
+// VerifySignedToken reports whether token was created by NewSignedToken for
+// the same purpose.
+func VerifySignedToken(purpose, token string) bool {
+	random, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(signToken(purpose, random)))
+}
+
+// SignValue encodes value and binds it to purpose with the same signature as
+// NewSignedToken, for state that has to travel through the client untouched.
+func SignValue(purpose string, value []byte) string {
+	encoded := base64.RawURLEncoding.EncodeToString(value)
+	return encoded + "." + signToken(purpose, encoded)
+}
+
+// VerifySignedValue returns the value of a string created by SignValue for
+// the same purpose.
+func VerifySignedValue(purpose, signed string) ([]byte, bool) {
+	if !VerifySignedToken(purpose, signed) {
+		return nil, false
+	}
+	encoded, _, _ := strings.Cut(signed, ".")
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func signToken(purpose, random string) string {
+	if secretKey == nil {
+		panic("utils: LoadSecretKey has not been called")
+	}
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write([]byte(purpose + "." + random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
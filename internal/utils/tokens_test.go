@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSecretKey(t *testing.T) {
+	t.Setenv("SECRET_KEY", "")
+	if err := LoadSecretKey(); err == nil {
+		t.Error("expected an empty SECRET_KEY to be refused")
+	}
+	t.Setenv("SECRET_KEY", strings.Repeat("k", MinSecretKeyLength-1))
+	if err := LoadSecretKey(); err == nil {
+		t.Error("expected a short SECRET_KEY to be refused")
+	}
+	t.Setenv("SECRET_KEY", strings.Repeat("k", MinSecretKeyLength))
+	if err := LoadSecretKey(); err != nil {
+		t.Fatalf("LoadSecretKey returned error: %v", err)
+	}
+}
+
+func TestSignedToken(t *testing.T) {
+	t.Setenv("SECRET_KEY", strings.Repeat("k", MinSecretKeyLength))
+	if err := LoadSecretKey(); err != nil {
+		t.Fatalf("LoadSecretKey returned error: %v", err)
+	}
+	token, err := NewSignedToken("reset")
+	if err != nil {
+		t.Fatalf("NewSignedToken returned error: %v", err)
+	}
+	if !VerifySignedToken("reset", token) {
+		t.Error("expected the token to verify for its purpose")
+	}
+	if VerifySignedToken("verify", token) {
+		t.Error("expected the token to be rejected for another purpose")
+	}
+
+	signed := SignValue("state", []byte("hello"))
+	if value, ok := VerifySignedValue("state", signed); !ok || string(value) != "hello" {
+		t.Errorf("VerifySignedValue = %q, %v", value, ok)
+	}
+	if _, ok := VerifySignedValue("state", signed+"x"); ok {
+		t.Error("expected a tampered value to be rejected")
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the ASCII key "12345678901234567890" from RFC 6238
+// appendix B, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists 8 digit codes; ours are the last 6 digits of those.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	previous, _ := TOTPCode(rfc6238Secret, TOTPStep(now)-1)
+	if step, ok := ValidateTOTP(rfc6238Secret, previous, now); !ok || step != TOTPStep(now)-1 {
+		t.Errorf("expected code of the previous step to be accepted")
+	}
+	stale, _ := TOTPCode(rfc6238Secret, TOTPStep(now)-2)
+	if _, ok := ValidateTOTP(rfc6238Secret, stale, now); ok {
+		t.Errorf("expected code from two steps ago to be rejected")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "12345", now); ok {
+		t.Errorf("expected short code to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("rytr", "jane@example.com", rfc6238Secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/rytr:jane@example.com?") {
+		t.Errorf("unexpected uri prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+rfc6238Secret) {
+		t.Errorf("expected uri to contain secret: %s", uri)
+	}
+}